@@ -24,6 +24,7 @@ import (
 	"net/http"
 
 	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/proxy"
 )
 
 func JoinServer(w http.ResponseWriter, r *http.Request) {
@@ -44,11 +45,23 @@ func JoinServer(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad login", http.StatusOK)
 		return
 	}
-	if r.URL.Query().Get("user") != username {
+	if !db.SameUsername(r.URL.Query().Get("user"), username) {
 		http.Error(w, "Bad login", http.StatusOK)
 		return
 	}
 
+	ban, err := banMessage(r.Context(), username)
+	if err != nil {
+		http.Error(w, "Bad response", http.StatusInternalServerError)
+		return
+	}
+	if ban != "" {
+		http.Error(w, ban, http.StatusOK)
+		return
+	}
+
+	db.TouchSession(r.Context(), sessionId, proxy.ClientIP(r))
+
 	err = db.SetUserServerID(r.Context(), username, serverId)
 	if err != nil {
 		http.Error(w, "Bad response", http.StatusBadRequest)
@@ -70,10 +83,22 @@ func Session(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad login", http.StatusOK)
 		return
 	}
-	if r.URL.Query().Get("name") != username {
+	if !db.SameUsername(r.URL.Query().Get("name"), username) {
 		http.Error(w, "Bad login", http.StatusOK)
 		return
 	}
 
+	ban, err := banMessage(r.Context(), username)
+	if err != nil {
+		http.Error(w, "Bad response", http.StatusInternalServerError)
+		return
+	}
+	if ban != "" {
+		http.Error(w, ban, http.StatusOK)
+		return
+	}
+
+	db.TouchSession(r.Context(), session, proxy.ClientIP(r))
+
 	fmt.Fprint(w, "OK")
 }
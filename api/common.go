@@ -19,8 +19,13 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"encoding/hex"
 	"errors"
+	"fmt"
+
+	"github.com/patapancakes/betablock/db"
 )
 
 var ErrServerIdTooLong = errors.New("server id is too long")
@@ -41,3 +46,22 @@ func GetPaddedServerID(sid string) ([]byte, error) {
 
 	return serverId, nil
 }
+
+// banMessage returns the message the launcher and client show a banned user,
+// or an empty string if the user isn't banned
+func banMessage(ctx context.Context, username string) (string, error) {
+	ban, err := db.GetActiveBan(ctx, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	if ban.Permanent() {
+		return fmt.Sprintf("Account banned: %s", ban.Reason), nil
+	}
+
+	return fmt.Sprintf("Account suspended until %s UTC: %s", ban.Expires.UTC().Format("2006-01-02 15:04"), ban.Reason), nil
+}
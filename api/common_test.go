@@ -0,0 +1,107 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestBans(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		expires time.Time
+		lift    bool
+		message string
+	}{
+		{"permanent", time.Time{}, false, "Account banned: testing"},
+		{"suspended", time.Now().Add(time.Hour), false, "Account suspended until "},
+		{"expired", time.Now().Add(-time.Hour), false, ""},
+		{"lifted", time.Time{}, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username := dbtest.Account(t, "correct horse")
+
+			err := db.InsertBan(ctx, username, "testing", "Admin", tt.expires)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.lift {
+				err = db.LiftBans(ctx, username)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// launcher login
+			form := url.Values{"user": {username}, "password": {"correct horse"}, "version": {"13"}}
+
+			r := httptest.NewRequest("POST", "/game/getversion.jsp", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			w := httptest.NewRecorder()
+			Login(w, r)
+
+			body := strings.TrimSpace(w.Body.String())
+			if tt.message != "" && !strings.HasPrefix(body, tt.message) {
+				t.Errorf("login returned %q, want %q", body, tt.message)
+			}
+			if tt.message == "" && !strings.Contains(body, ":"+username+":") {
+				t.Errorf("login returned %q for an account that isn't banned", body)
+			}
+
+			// joining a server
+			session := dbtest.Token(t)
+
+			err = db.InsertSession(ctx, username, session, db.SessionLauncher, "192.0.2.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			query := url.Values{"user": {username}, "sessionId": {hex.EncodeToString(session)}, "serverId": {"1234abcd"}}
+
+			w = httptest.NewRecorder()
+			JoinServer(w, httptest.NewRequest("GET", "/game/joinserver.jsp?"+query.Encode(), nil))
+
+			body = strings.TrimSpace(w.Body.String())
+			want := tt.message
+			if want == "" {
+				want = "OK"
+			}
+
+			if !strings.HasPrefix(body, want) {
+				t.Errorf("join returned %q, want %q", body, want)
+			}
+		})
+	}
+}
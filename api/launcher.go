@@ -24,22 +24,94 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/proxy"
+	"github.com/patapancakes/betablock/ratelimit"
 )
 
 func Login(w http.ResponseWriter, r *http.Request) {
+	ip := proxy.ClientIP(r)
+
+	// there's no captcha here, so limit how fast passwords can be guessed
+	wait, err := ratelimit.CheckLogin(r.Context(), ip, r.PostFormValue("user"))
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if wait != 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "Too many login attempts, try again later", http.StatusOK)
+		return
+	}
+
 	username, err := db.GetCanonicalUsername(r.Context(), r.PostFormValue("user"))
 	if err != nil {
+		ratelimit.LoginFailed(r.Context(), ip, r.PostFormValue("user"))
 		http.Error(w, "Bad login", http.StatusOK)
 		return
 	}
 
-	// password
-	err = db.ValidatePassword(r.Context(), username, r.PostFormValue("password"))
+	// password, either an app password or the account password. the
+	// launcher can't do two-factor authentication so those accounts have to
+	// use an app password.
+	id, err := db.ValidateAppPassword(r.Context(), username, r.PostFormValue("password"))
+	if err == nil {
+		db.TouchAppPassword(r.Context(), id, ip)
+	} else {
+		secret, _, err := db.GetTOTP(r.Context(), username)
+		if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+
+		err = db.ValidatePassword(r.Context(), username, r.PostFormValue("password"))
+		if err != nil {
+			ratelimit.LoginFailed(r.Context(), ip, username)
+			http.Error(w, "Bad login", http.StatusOK)
+			return
+		}
+
+		if secret != "" {
+			http.Error(w, "Use an app password", http.StatusOK)
+			return
+		}
+	}
+
+	ratelimit.LoginSucceeded(r.Context(), username)
+
+	// bans
+	ban, err := banMessage(r.Context(), username)
 	if err != nil {
-		http.Error(w, "Bad login", http.StatusOK)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if ban != "" {
+		http.Error(w, ban, http.StatusOK)
+		return
+	}
+
+	// registrations awaiting approval
+	pending, err := db.IsAccountPending(r.Context(), username)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if pending {
+		http.Error(w, "Account awaiting approval", http.StatusOK)
+		return
+	}
+
+	// pending deletion, only cancellable on the website
+	_, err = db.GetScheduledDeletion(r.Context(), username)
+	if err == nil {
+		http.Error(w, "Account scheduled for deletion, log in on the website to cancel", http.StatusOK)
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -65,7 +137,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = db.InsertSession(r.Context(), username, session)
+	err = db.InsertSession(r.Context(), username, session, db.SessionLauncher, proxy.ClientIP(r))
 	if err != nil {
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
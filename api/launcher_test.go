@@ -0,0 +1,87 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestLoginCase(t *testing.T) {
+	dbtest.Init(t)
+
+	username := dbtest.Account(t, "correct horse")
+
+	for _, name := range []string{username, strings.ToLower(username), strings.ToUpper(username)} {
+		form := url.Values{"user": {name}, "password": {"correct horse"}, "version": {"13"}}
+
+		r := httptest.NewRequest("POST", "/game/getversion.jsp", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		Login(w, r)
+
+		// the launcher is told the name the account really has
+		body := strings.TrimSpace(w.Body.String())
+		if !strings.Contains(body, ":"+username+":") {
+			t.Errorf("%s: login returned %q, want the username %s", name, body, username)
+		}
+	}
+}
+
+func TestJoinServerCase(t *testing.T) {
+	dbtest.Init(t)
+
+	username := dbtest.Account(t, "correct horse")
+	session := dbtest.Token(t)
+
+	err := db.InsertSession(context.Background(), username, session, db.SessionLauncher, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user string
+		want string
+	}{
+		{username, "OK"},
+		{strings.ToLower(username), "OK"},
+		{strings.ToUpper(username), "OK"},
+		{username + "x", "Bad login"},
+	}
+
+	for _, tt := range tests {
+		query := url.Values{"user": {tt.user}, "sessionId": {hex.EncodeToString(session)}, "serverId": {"1234abcd"}}
+
+		w := httptest.NewRecorder()
+		JoinServer(w, httptest.NewRequest("GET", "/game/joinserver.jsp?"+query.Encode(), nil))
+
+		body := strings.TrimSpace(w.Body.String())
+		if body != tt.want {
+			t.Errorf("%s: join returned %q, want %q", tt.user, body, tt.want)
+		}
+	}
+}
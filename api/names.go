@@ -0,0 +1,109 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/patapancakes/betablock/db"
+)
+
+type Profile struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type NameEntry struct {
+	Name        string `json:"name"`
+	ChangedToAt int64  `json:"changedToAt,omitempty"` // unix milliseconds
+}
+
+// GetProfile looks up the account currently using a username
+func GetProfile(w http.ResponseWriter, r *http.Request) {
+	username, err := db.GetCanonicalUsername(r.Context(), r.PathValue("username"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := db.GetAccountID(r.Context(), username)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(Profile{ID: id, Name: username})
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// NameHistory lists every name an account has had, oldest first, in the
+// shape of the classic names api
+func NameHistory(w http.ResponseWriter, r *http.Request) {
+	// accept both the dashed and undashed forms
+	id := strings.ToLower(strings.ReplaceAll(r.PathValue("id"), "-", ""))
+
+	username, err := db.GetUsernameFromID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	history, err := db.GetNameHistory(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	// each change is when the previous name was left for the next one
+	var names []NameEntry
+	var changedToAt int64
+	for _, change := range history {
+		names = append(names, NameEntry{Name: change.Username, ChangedToAt: changedToAt})
+		changedToAt = change.Changed.UnixMilli()
+	}
+
+	names = append(names, NameEntry{Name: username, ChangedToAt: changedToAt})
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(names)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -33,7 +33,17 @@ func CheckServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	username := r.URL.Query().Get("user")
+	username, err := db.GetCanonicalUsername(r.Context(), r.URL.Query().Get("user"))
+	if err != nil {
+		fmt.Fprint(w, "NO")
+		return
+	}
+
+	ban, err := banMessage(r.Context(), username)
+	if err != nil || ban != "" {
+		fmt.Fprint(w, "NO")
+		return
+	}
 
 	sid, err := db.GetUserServerID(r.Context(), username)
 	if err != nil {
@@ -19,17 +19,28 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/patapancakes/betablock/api"
 	"github.com/patapancakes/betablock/cdn"
+	"github.com/patapancakes/betablock/challenge"
 	"github.com/patapancakes/betablock/db"
 	"github.com/patapancakes/betablock/frontend"
+	"github.com/patapancakes/betablock/mail"
 	"github.com/patapancakes/betablock/news"
+	"github.com/patapancakes/betablock/passwords"
+	"github.com/patapancakes/betablock/proxy"
+	"github.com/patapancakes/betablock/ratelimit"
+	"github.com/patapancakes/betablock/storage"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -44,16 +55,231 @@ func main() {
 		log.Fatalf("error in database init: %s", err)
 	}
 
+	err = db.Migrate(context.Background())
+	if err != nil {
+		log.Fatalf("error in database migration: %s", err)
+	}
+
+	// staff, so a new install has someone who can assign roles
+	for _, name := range strings.FieldsFunc(os.Getenv("ADMIN_USERS"), func(r rune) bool { return r == ',' || r == ' ' }) {
+		username, err := db.GetCanonicalUsername(context.Background(), name)
+		if err != nil {
+			log.Printf("failed to make %s an admin: %s", name, err)
+			continue
+		}
+
+		err = db.SetUserRole(context.Background(), username, db.RoleAdmin)
+		if err != nil {
+			log.Fatalf("error making %s an admin: %s", username, err)
+		}
+	}
+
+	// init object storage
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "", "local":
+		dir := os.Getenv("STORAGE_PATH")
+		if dir == "" {
+			dir = "public"
+		}
+
+		local, err := storage.NewLocal(dir, storage.SymlinkPolicy(os.Getenv("STORAGE_SYMLINKS")))
+		if err != nil {
+			log.Fatalf("error in object storage init: %s", err)
+		}
+
+		storage.Init(local)
+	case "s3":
+		s3, err := storage.NewS3(os.Getenv("S3_ENDPOINT"), os.Getenv("S3_REGION"), os.Getenv("S3_BUCKET"), os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"))
+		if err != nil {
+			log.Fatalf("error in object storage init: %s", err)
+		}
+
+		storage.Init(s3)
+	default:
+		log.Fatalf("unknown storage driver %q", os.Getenv("STORAGE_DRIVER"))
+	}
+
+	// init mailer
+	switch os.Getenv("MAIL_DRIVER") {
+	case "":
+	case "smtp":
+		smtp, err := mail.NewSMTP(os.Getenv("SMTP_ADDR"), os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASS"), os.Getenv("MAIL_FROM"))
+		if err != nil {
+			log.Fatalf("error in mailer init: %s", err)
+		}
+
+		mail.Init(smtp)
+	case "log":
+		l, err := mail.NewLog(os.Getenv("MAIL_LOG_PATH"))
+		if err != nil {
+			log.Fatalf("error in mailer init: %s", err)
+		}
+
+		mail.Init(l)
+	default:
+		log.Fatalf("unknown mail driver %q", os.Getenv("MAIL_DRIVER"))
+	}
+
+	// passwords
+	if os.Getenv("BCRYPT_COST") != "" {
+		cost, err := strconv.Atoi(os.Getenv("BCRYPT_COST"))
+		if err != nil {
+			log.Fatalf("invalid bcrypt cost: %s", err)
+		}
+
+		err = db.SetBcryptCost(cost)
+		if err != nil {
+			log.Fatalf("invalid bcrypt cost: %s", err)
+		}
+	}
+
+	if os.Getenv("PASSWORD_MIN_LENGTH") != "" {
+		length, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+		if err != nil {
+			log.Fatalf("invalid minimum password length: %s", err)
+		}
+
+		passwords.MinLength = length
+	}
+
+	// registration
+	if os.Getenv("REGISTRATION_MODE") != "" && !slices.Contains(frontend.RegistrationModes, os.Getenv("REGISTRATION_MODE")) {
+		log.Fatalf("unknown registration mode %q", os.Getenv("REGISTRATION_MODE"))
+	}
+
+	// proxies
+	err = proxy.Init(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("error in trusted proxy init: %s", err)
+	}
+
+	// rate limits
+	switch os.Getenv("RATELIMIT_STORE") {
+	case "", "memory":
+		// default
+	case "db":
+		ratelimit.Init(db.RateLimits{})
+
+		go func() {
+			for range time.Tick(time.Hour) {
+				err := db.DeleteStaleRateLimits(context.Background(), time.Now().Add(-24*time.Hour))
+				if err != nil {
+					log.Printf("failed to delete stale rate limits: %s", err)
+				}
+			}
+		}()
+	default:
+		log.Fatalf("unknown rate limit store %q", os.Getenv("RATELIMIT_STORE"))
+	}
+
+	// challenges
+	switch os.Getenv("CHALLENGE_PROVIDER") {
+	case "":
+		// TS_SITE_KEY and TS_SECRET_KEY configured turnstile before there
+		// were other providers
+		if os.Getenv("TS_SITE_KEY") != "" {
+			log.Printf("TS_SITE_KEY and TS_SECRET_KEY are deprecated, set CHALLENGE_PROVIDER=turnstile, CHALLENGE_SITE_KEY and CHALLENGE_SECRET_KEY instead")
+
+			challenge.Init(challenge.NewTurnstile(os.Getenv("TS_SITE_KEY"), os.Getenv("TS_SECRET_KEY"), os.Getenv("CHALLENGE_VERIFY_URL")))
+		}
+	case "turnstile":
+		challenge.Init(challenge.NewTurnstile(os.Getenv("CHALLENGE_SITE_KEY"), os.Getenv("CHALLENGE_SECRET_KEY"), os.Getenv("CHALLENGE_VERIFY_URL")))
+	case "hcaptcha":
+		challenge.Init(challenge.NewHCaptcha(os.Getenv("CHALLENGE_SITE_KEY"), os.Getenv("CHALLENGE_SECRET_KEY"), os.Getenv("CHALLENGE_VERIFY_URL")))
+	case "pow":
+		difficulty, err := strconv.Atoi(os.Getenv("POW_DIFFICULTY"))
+		if err != nil || difficulty < 1 || difficulty > 32 {
+			log.Fatalf("invalid proof-of-work difficulty %q, it must be a number from 1 to 32", os.Getenv("POW_DIFFICULTY"))
+		}
+
+		pow, err := challenge.NewProofOfWork([]byte(os.Getenv("POW_KEY")), difficulty)
+		if err != nil {
+			log.Fatalf("error in challenge init: %s", err)
+		}
+
+		challenge.Init(pow)
+	case "noop":
+		challenge.Init(challenge.Noop{})
+	default:
+		log.Fatalf("unknown challenge provider %q", os.Getenv("CHALLENGE_PROVIDER"))
+	}
+
+	// cookies
+	sameSite, err := frontend.ParseSameSite(os.Getenv("COOKIE_SAMESITE"))
+	if err != nil {
+		log.Fatalf("invalid cookie samesite mode: %s", err)
+	}
+
+	secure := true
+	if os.Getenv("COOKIE_SECURE") != "" {
+		secure, err = strconv.ParseBool(os.Getenv("COOKIE_SECURE"))
+		if err != nil {
+			log.Fatalf("invalid cookie secure flag: %s", err)
+		}
+	}
+
+	if os.Getenv("COOKIE_KEY") == "" {
+		log.Printf("COOKIE_KEY is not set, sessions will not survive restarts")
+	}
+
+	err = frontend.InitCookies([]byte(os.Getenv("COOKIE_KEY")), frontend.CookieOptions{
+		Secure:   secure,
+		SameSite: sameSite,
+		Domain:   os.Getenv("COOKIE_DOMAIN"),
+	})
+	if err != nil {
+		log.Fatalf("error in cookie init: %s", err)
+	}
+
+	// accounts past their deletion grace period
+	go func() {
+		for range time.Tick(time.Hour) {
+			err := frontend.PurgeDeletedAccounts(context.Background())
+			if err != nil {
+				log.Printf("failed to purge deleted accounts: %s", err)
+			}
+		}
+	}()
+
+	// sessions past their expiry
+	go func() {
+		for range time.Tick(time.Hour) {
+			err := db.DeleteExpiredSessions(context.Background())
+			if err != nil {
+				log.Printf("failed to delete expired sessions: %s", err)
+			}
+		}
+	}()
+
 	// frontend
-	http.HandleFunc("/", frontend.About)
-	http.HandleFunc("/download", frontend.Download)
-	http.HandleFunc("/register", frontend.Register)
-	http.HandleFunc("/login", frontend.Login)
-	http.HandleFunc("/logout", frontend.Logout)
-	http.HandleFunc("/setskin", frontend.SetCosmetic)
-	http.HandleFunc("/setcape", frontend.SetCosmetic)
-	http.HandleFunc("/setversion", frontend.SetVersion)
-	http.HandleFunc("/changepw", frontend.ChangePW)
+	http.HandleFunc("/", frontend.CSRF(frontend.About))
+	http.HandleFunc("/download", frontend.CSRF(frontend.Challenged(challenge.Posts, frontend.Download)))
+	http.HandleFunc("/register", frontend.CSRF(frontend.Challenged(challenge.Posts, frontend.Register)))
+	http.HandleFunc("/login", frontend.CSRF(frontend.Challenged(frontend.LoginPolicy, frontend.Login)))
+	http.HandleFunc("POST /logout", frontend.CSRF(frontend.Logout))
+	http.HandleFunc("/setskin", frontend.CSRF(frontend.Challenged(challenge.Posts, frontend.SetCosmetic)))
+	http.HandleFunc("/setcape", frontend.CSRF(frontend.Challenged(challenge.Posts, frontend.SetCosmetic)))
+	http.HandleFunc("/setversion", frontend.CSRF(frontend.SetVersion))
+	http.HandleFunc("/rename", frontend.CSRF(frontend.Rename))
+	http.HandleFunc("/changepw", frontend.CSRF(frontend.Challenged(challenge.Posts, frontend.ChangePW)))
+	http.HandleFunc("/sessions", frontend.CSRF(frontend.Sessions))
+	http.HandleFunc("/reauth", frontend.CSRF(frontend.Reauth))
+	http.HandleFunc("/twofactor", frontend.CSRF(frontend.TwoFactor))
+	http.HandleFunc("/apppasswords", frontend.CSRF(frontend.AppPasswords))
+	http.HandleFunc("/invites", frontend.CSRF(frontend.Invites))
+	http.HandleFunc("/delete", frontend.CSRF(frontend.DeleteAccount))
+	http.HandleFunc("GET /export", frontend.CSRF(frontend.Export))
+	http.HandleFunc("/email", frontend.CSRF(frontend.Challenged(challenge.Posts, frontend.Email)))
+	http.HandleFunc("GET /verify", frontend.CSRF(frontend.Verify))
+	http.HandleFunc("/forgot", frontend.CSRF(frontend.Challenged(challenge.Posts, frontend.Forgot)))
+	http.HandleFunc("/reset", frontend.CSRF(frontend.Reset))
+	http.HandleFunc("/admin", frontend.CSRF(frontend.Admin))
+	http.HandleFunc("/admin/user", frontend.CSRF(frontend.AdminUser))
+	http.HandleFunc("/admin/capes", frontend.CSRF(frontend.AdminCapes))
+	http.HandleFunc("/admin/moderation", frontend.CSRF(frontend.AdminModeration))
+	http.HandleFunc("GET /preview/{kind}/{hash}", frontend.Preview)
+
+	http.HandleFunc("GET /challenge", challenge.Handler)
 
 	http.Handle("GET /assets/", http.FileServerFS(frontend.AssetsFS))
 
@@ -63,16 +289,21 @@ func main() {
 	// server
 	http.HandleFunc("GET api.betablock.net/server/checkserver", api.CheckServer)
 
+	// names
+	http.HandleFunc("GET api.betablock.net/users/profiles/minecraft/{username}", api.GetProfile)
+	http.HandleFunc("GET api.betablock.net/user/profiles/{id}/names", api.NameHistory)
+
 	// client
 	http.HandleFunc("GET api.betablock.net/client/joinserver", api.JoinServer)
 	http.HandleFunc("GET api.betablock.net/client/session", api.Session)
 	http.HandleFunc("GET api.betablock.net/client/resources/", cdn.HandleLegacyResources)
 	http.HandleFunc("GET api.betablock.net/client/cloak", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, "//cdn.betablock.net/MinecraftCloaks/"+r.URL.Query().Get("user")+".png", http.StatusMovedPermanently)
+		http.Redirect(w, r, "//cdn.betablock.net/capes/"+r.URL.Query().Get("user")+".png", http.StatusMovedPermanently)
 	})
 
 	// cdn
 	http.HandleFunc("cdn.betablock.net/", cdn.Handle)
+	http.HandleFunc("GET cdn.betablock.net/renders/{render}/{file}", cdn.HandleRender)
 
 	// news
 	http.HandleFunc("GET news.betablock.net/", news.Handle)
@@ -0,0 +1,49 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/patapancakes/betablock/storage"
+)
+
+// denied holds path.Match patterns for keys that are never served, matched
+// against both the full key and its base name
+var denied = strings.FieldsFunc(os.Getenv("CDN_DENY"), func(r rune) bool { return r == ',' || r == ' ' })
+
+// isServable reports whether the object at key may be served to clients
+func isServable(key string) bool {
+	if storage.ValidKey(key) != nil {
+		return false
+	}
+
+	for _, pattern := range denied {
+		if ok, _ := path.Match(pattern, key); ok {
+			return false
+		}
+		if ok, _ := path.Match(pattern, path.Base(key)); ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,59 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestDeniedListings(t *testing.T) {
+	initStore(t, map[string]string{
+		"resources/sound/a.ogg":     "a",
+		"resources/sound/a.ogg.bak": "old a",
+		"resources/private/notes":   "notes",
+		"binaries/minecraft.jar":    "jar",
+		"binaries/lwjgl.jar.bak":    "old jar",
+	})
+
+	saved := denied
+	t.Cleanup(func() { denied = saved })
+
+	denied = []string{"*.bak", "resources/private/*"}
+
+	for _, key := range []string{"resources/sound/a.ogg.bak", "resources/private/notes", "binaries/lwjgl.jar.bak"} {
+		if isServable(key) {
+			t.Errorf("%s is servable", key)
+		}
+	}
+
+	keys := resourceKeys(t, "")
+	if !slices.Equal(keys, []string{"sound/a.ogg"}) {
+		t.Errorf("resources list %q, want only sound/a.ogg", keys)
+	}
+
+	files, err := getFiles(context.Background(), "binaries/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Key != "minecraft.jar" {
+		t.Errorf("binaries list %+v, want only minecraft.jar", files)
+	}
+}
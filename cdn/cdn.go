@@ -21,6 +21,7 @@ package cdn
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"crypto/md5"
 	"database/sql"
 	"encoding/hex"
@@ -28,18 +29,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"mime"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"slices"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/patapancakes/betablock/db"
 	"github.com/patapancakes/betablock/patcher"
+	"github.com/patapancakes/betablock/storage"
 )
 
 type ListBucketResult struct {
@@ -57,14 +58,18 @@ type Object struct {
 }
 
 func Handle(w http.ResponseWriter, r *http.Request) {
-	file := filepath.Join("public", r.URL.Path)
+	key := strings.TrimPrefix(r.URL.Path, "/")
 
 	// object list
 	if slices.Contains([]string{"/binaries/", "/resources/"}, r.URL.Path) {
 		var err error
 
 		res := ListBucketResult{Name: path.Base(r.URL.Path)}
-		res.Contents, err = getFiles(file)
+		if r.URL.Path == "/resources/" {
+			res.Contents, err = getResources(r.Context(), resourceVersion(r))
+		} else {
+			res.Contents, err = getFiles(r.Context(), key)
+		}
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			return
@@ -81,7 +86,11 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var of io.Reader
+	var (
+		content  io.ReadSeeker
+		modified time.Time
+		hash     string
+	)
 
 	switch r.URL.Path {
 	case "/binaries/minecraft.jar": // handle version selection and patching
@@ -97,7 +106,7 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if r.URL.Query().Get("user") != username {
+		if !db.SameUsername(r.URL.Query().Get("user"), username) {
 			http.Error(w, "username mismatch", http.StatusUnauthorized)
 			return
 		}
@@ -144,99 +153,111 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		of = patched
+		w.Header().Set("Content-Type", "application/java-archive")
+
+		// no modification time, the output depends on the user's selected version
+		content = bytes.NewReader(patched.Bytes())
+		hash = fmt.Sprintf("%x", md5.Sum(patched.Bytes()))
 	default: // normal file download
-		f, err := os.Open(file)
-		if err != nil {
-			if os.IsNotExist(err) {
+		if strings.HasSuffix(key, "/") || key == "" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if rkey, ok := strings.CutPrefix(key, "resources/"); ok {
+			key, ok = resolveResource(r.Context(), resourceVersion(r), rkey)
+			if !ok {
 				w.WriteHeader(http.StatusNotFound)
 				return
 			}
+		}
 
-			w.WriteHeader(http.StatusInternalServerError)
+		key = canonicalCosmeticKey(r.Context(), key)
+
+		if !isServable(key) {
+			w.WriteHeader(http.StatusNotFound)
 			return
 		}
 
-		defer f.Close()
+		// uploads awaiting moderation or rejected by it aren't public
+		if hash, ok := strings.CutPrefix(key, "cosmetics/"); ok {
+			approved, err := db.IsCosmeticApproved(r.Context(), strings.TrimSuffix(hash, ".png"))
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if !approved {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
 
-		s, err := f.Stat()
+		f, info, err := storage.Open(r.Context(), key)
 		if err != nil {
+			if errors.Is(err, storage.ErrNotExist) || errors.Is(err, storage.ErrSymlink) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		if s.IsDir() {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
 
-		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(r.URL.Path)))
-		of = f
-	}
-
-	b, err := io.ReadAll(of)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to read output data: %s", err), http.StatusInternalServerError)
-		return
-	}
+		defer f.Close()
 
-	etag := fmt.Sprintf("\"%x\"", md5.Sum(b))
+		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(r.URL.Path)))
 
-	if r.Header.Get("If-None-Match") == etag {
-		w.WriteHeader(http.StatusNotModified)
-		return
+		content = f
+		modified = info.Modified
+		hash = info.Hash
 	}
 
-	w.Header().Set("ETag", etag)
-	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
-	w.Write(b)
+	// handles HEAD, Range, If-Range, If-None-Match and If-Modified-Since
+	w.Header().Set("ETag", "\""+hash+"\"")
+	http.ServeContent(w, r, path.Base(r.URL.Path), modified, content)
 }
 
-func getFiles(base string) ([]Object, error) {
-	var files []Object
-
-	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if d.IsDir() {
-			return nil
-		}
+// canonicalCosmeticKey rewrites the key of a published cosmetic to use its
+// owner's canonical username, so any casing of the name finds it
+func canonicalCosmeticKey(ctx context.Context, key string) string {
+	dir, file := path.Split(key)
+	if !slices.Contains([]string{"skins/", "capes/", "originals/skins/", "originals/capes/"}, dir) {
+		return key
+	}
 
-		f, err := os.Open(path)
-		if err != nil {
-			return err
-		}
+	name, ok := strings.CutSuffix(file, ".png")
+	if !ok {
+		return key
+	}
 
-		defer f.Close()
+	username, err := db.GetCanonicalUsername(ctx, name)
+	if err != nil {
+		return key
+	}
 
-		stat, err := f.Stat()
-		if err != nil {
-			return err
-		}
+	return dir + username + ".png"
+}
 
-		hash := md5.New()
-		_, err = io.Copy(hash, f)
-		if err != nil {
-			return err
-		}
+// getFiles lists the objects below prefix with keys relative to it, leaving
+// out the ones that aren't servable
+func getFiles(ctx context.Context, prefix string) ([]Object, error) {
+	objects, err := storage.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
 
-		rel, err := filepath.Rel(base, path)
-		if err != nil {
-			return err
+	var files []Object
+	for _, o := range objects {
+		if !isServable(o.Key) {
+			continue
 		}
 
 		files = append(files, Object{
-			Key:      rel,
-			Size:     int(stat.Size()),
-			Modified: stat.ModTime(),
-			Hash:     hex.EncodeToString(hash.Sum(nil)),
+			Key:      strings.TrimPrefix(o.Key, prefix),
+			Size:     int(o.Size),
+			Modified: o.Modified,
+			Hash:     o.Hash,
 		})
-
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
 
 	return files, nil
@@ -0,0 +1,197 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patapancakes/betablock/storage"
+)
+
+func TestHandleConditional(t *testing.T) {
+	dir := initStore(t, map[string]string{"binaries/lwjgl.jar": "0123456789"})
+
+	modified := time.Date(2010, time.October, 31, 12, 0, 0, 0, time.UTC)
+	err := os.Chtimes(filepath.Join(dir, "binaries", "lwjgl.jar"), modified, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	etag := fmt.Sprintf("\"%x\"", md5.Sum([]byte("0123456789")))
+
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		status int
+		body   string
+		crange string
+		noBody bool
+	}{
+		{"full", http.MethodGet, nil, http.StatusOK, "0123456789", "", false},
+		{"head", http.MethodHead, nil, http.StatusOK, "", "", true},
+		{"range", http.MethodGet, map[string]string{"Range": "bytes=2-5"}, http.StatusPartialContent, "2345", "bytes 2-5/10", false},
+		{"suffix range", http.MethodGet, map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "789", "bytes 7-9/10", false},
+		{"unsatisfiable range", http.MethodGet, map[string]string{"Range": "bytes=20-30"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */10", false},
+		{"if-range etag", http.MethodGet, map[string]string{"Range": "bytes=2-5", "If-Range": etag}, http.StatusPartialContent, "2345", "bytes 2-5/10", false},
+		{"stale if-range etag", http.MethodGet, map[string]string{"Range": "bytes=2-5", "If-Range": "\"stale\""}, http.StatusOK, "0123456789", "", false},
+		{"if-range date", http.MethodGet, map[string]string{"Range": "bytes=2-5", "If-Range": modified.Format(http.TimeFormat)}, http.StatusPartialContent, "2345", "bytes 2-5/10", false},
+		{"stale if-range date", http.MethodGet, map[string]string{"Range": "bytes=2-5", "If-Range": modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK, "0123456789", "", false},
+		{"if-none-match", http.MethodGet, map[string]string{"If-None-Match": etag}, http.StatusNotModified, "", "", true},
+		{"if-modified-since", http.MethodGet, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusNotModified, "", "", true},
+		{"modified since", http.MethodGet, map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK, "0123456789", "", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/binaries/lwjgl.jar", nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		Handle(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+			continue
+		}
+		if tt.noBody && w.Body.Len() != 0 {
+			t.Errorf("%s: body %q, want none", tt.name, w.Body)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s: body %q, want %q", tt.name, w.Body, tt.body)
+		}
+		if w.Header().Get("Content-Range") != tt.crange {
+			t.Errorf("%s: Content-Range %q, want %q", tt.name, w.Header().Get("Content-Range"), tt.crange)
+		}
+		if tt.status != http.StatusRequestedRangeNotSatisfiable && w.Header().Get("ETag") != etag {
+			t.Errorf("%s: ETag %q, want %q", tt.name, w.Header().Get("ETag"), etag)
+		}
+	}
+
+	// HEAD still describes the full object
+	r := httptest.NewRequest(http.MethodHead, "/binaries/lwjgl.jar", nil)
+	w := httptest.NewRecorder()
+	Handle(w, r)
+
+	if w.Header().Get("Content-Length") != "10" {
+		t.Errorf("HEAD Content-Length %q, want 10", w.Header().Get("Content-Length"))
+	}
+	if w.Header().Get("Last-Modified") != modified.Format(http.TimeFormat) {
+		t.Errorf("HEAD Last-Modified %q, want %q", w.Header().Get("Last-Modified"), modified.Format(http.TimeFormat))
+	}
+}
+
+// touchesDB reports whether serving key would look it up in the database,
+// which the fuzzer runs without
+func touchesDB(key string) bool {
+	dir, file := path.Split(key)
+
+	return key == "binaries/minecraft.jar" || strings.HasPrefix(key, "cosmetics/") ||
+		slices.Contains([]string{"skins/", "capes/", "originals/skins/", "originals/capes/"}, dir) && strings.HasSuffix(file, ".png")
+}
+
+func FuzzHandle(f *testing.F) {
+	const sentinel = "sentinel outside the store"
+
+	base := f.TempDir()
+	dir := filepath.Join(base, "public")
+
+	files := map[string]string{
+		filepath.Join(dir, "resources", "sound", "a.ogg"): "a",
+		filepath.Join(dir, "binaries", "lwjgl.jar"):       "jar",
+		filepath.Join(base, "secret.txt"):                 sentinel,
+	}
+	for p, content := range files {
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		err = os.WriteFile(p, []byte(content), 0644)
+		if err != nil {
+			f.Fatal(err)
+		}
+	}
+
+	err := os.Symlink(filepath.Join(base, "secret.txt"), filepath.Join(dir, "resources", "escape.txt"))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	s, err := storage.NewLocal(dir, storage.SymlinksRoot)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	storage.Init(s)
+
+	for _, seed := range []string{"/binaries/lwjgl.jar", "/resources/sound/a.ogg", "/resources/escape.txt", "/../secret.txt", "/resources/../../secret.txt", "/binaries/%2e%2e/secret.txt", `/resources\..\..\secret.txt`, "/resources/", "//secret.txt"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, p string) {
+		if touchesDB(strings.TrimPrefix(p, "/")) {
+			return
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.URL = &url.URL{Path: p}
+
+		w := httptest.NewRecorder()
+		Handle(w, r)
+
+		if strings.Contains(w.Body.String(), sentinel) {
+			t.Fatalf("%q served the file outside the store", p)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.URL = &url.URL{Path: "/client/resources/" + strings.TrimPrefix(p, "/")}
+
+		w = httptest.NewRecorder()
+		HandleLegacyResources(w, r)
+
+		// redirects only ever point at servable resources
+		location := w.Header().Get("Location")
+		if location == "" {
+			return
+		}
+
+		target, err := url.Parse(location)
+		if err != nil {
+			t.Fatalf("%q redirected to unparsable %q: %s", p, location, err)
+		}
+
+		key, ok := strings.CutPrefix(target.Path, "/")
+		if !ok || !strings.HasPrefix(key, "resources/") || !isServable(key) {
+			t.Fatalf("%q redirected to %q, outside the resources", p, location)
+		}
+	})
+}
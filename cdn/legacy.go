@@ -21,7 +21,7 @@ package cdn
 import (
 	"encoding/csv"
 	"net/http"
-	"path/filepath"
+	"net/url"
 	"strconv"
 	"strings"
 )
@@ -31,7 +31,7 @@ func HandleLegacyResources(w http.ResponseWriter, r *http.Request) {
 
 	// object list
 	if file == "" {
-		files, err := getFiles(filepath.Join("public/resources", file))
+		files, err := getResources(r.Context(), resourceVersion(r))
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			return
@@ -50,5 +50,15 @@ func HandleLegacyResources(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(w, r, "//cdn.betablock.net/resources/"+file, http.StatusMovedPermanently)
+	if !isServable(resourcesPrefix + file) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	target := "//cdn.betablock.net" + (&url.URL{Path: "/" + resourcesPrefix + file}).EscapedPath()
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
 }
@@ -0,0 +1,71 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/storage"
+)
+
+func HandleRender(w http.ResponseWriter, r *http.Request) {
+	username, ok := strings.CutSuffix(r.PathValue("file"), ".png")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	size := 64
+	if r.URL.Query().Has("size") {
+		var err error
+		size, err = strconv.Atoi(r.URL.Query().Get("size"))
+		if err != nil || size < 8 || size > 512 {
+			http.Error(w, "size must be between 8 and 512", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// renders are cached by the canonical username
+	canonical, err := db.GetCanonicalUsername(r.Context(), username)
+	if err == nil {
+		username = canonical
+	}
+
+	data, hash, err := cosmetic.GetRender(r.Context(), cosmetic.Render(r.PathValue("render")), username, size)
+	if err != nil {
+		if errors.Is(err, cosmetic.ErrUnknownRender) || errors.Is(err, storage.ErrNotExist) || errors.Is(err, storage.ErrInvalidKey) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("ETag", "\""+hash+"\"")
+	http.ServeContent(w, r, r.PathValue("file"), time.Time{}, bytes.NewReader(data))
+}
@@ -0,0 +1,244 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/storage"
+)
+
+const (
+	resourcesPrefix    = "resources/"
+	resourceSetsPrefix = "resourcesets/"
+)
+
+var isValidVersion = regexp.MustCompile("^[A-Za-z0-9_.-]+$").MatchString
+
+// resourceVersion returns the client version the request's resource listing
+// should be personalised for, or an empty string if it can't be determined
+func resourceVersion(r *http.Request) string {
+	query := r.URL.Query()
+
+	if version := query.Get("version"); version != "" {
+		return version
+	}
+
+	var username string
+	switch {
+	case query.Get("ticket") != "":
+		ticket, err := hex.DecodeString(query.Get("ticket"))
+		if err != nil {
+			return ""
+		}
+
+		username, err = db.GetUsernameFromTicket(r.Context(), ticket)
+		if err != nil {
+			return ""
+		}
+	case query.Get("session") != "":
+		session, err := hex.DecodeString(query.Get("session"))
+		if err != nil {
+			return ""
+		}
+
+		username, err = db.GetUsernameFromSession(r.Context(), session)
+		if err != nil {
+			return ""
+		}
+	default:
+		return ""
+	}
+
+	version, _ := db.GetUserClientVersion(r.Context(), username)
+	if slices.Contains([]string{"", "realtime"}, version) {
+		version, _, _ = db.GetRealtimeVersion(r.Context())
+	}
+
+	return version
+}
+
+// resourceSetTTL is how long a version's overlay is remembered, so changes
+// to overlays show up after at most this long
+const resourceSetTTL = time.Minute
+
+// maxResourceSets caps how many versions' overlays are remembered, versions
+// come from requests so there could be any number of them
+const maxResourceSets = 256
+
+// resourceSet is a version's overlay on the base resource tree
+type resourceSet struct {
+	prefix   string   // empty if the version has no overlay
+	files    []Object // keys relative to prefix
+	excludes []string // base keys the overlay hides
+	loaded   time.Time
+}
+
+var resourceSets = struct {
+	sync.RWMutex
+	entries map[string]resourceSet
+}{entries: make(map[string]resourceSet)}
+
+// getResourceSet returns the given version's overlay. A version has one if
+// there are objects below its prefix or it has an exclude list.
+func getResourceSet(ctx context.Context, version string) (resourceSet, error) {
+	if !isValidVersion(version) || version == "." || version == ".." {
+		return resourceSet{}, nil
+	}
+
+	resourceSets.RLock()
+	set, ok := resourceSets.entries[version]
+	resourceSets.RUnlock()
+
+	if ok && time.Since(set.loaded) < resourceSetTTL {
+		return set, nil
+	}
+
+	prefix := resourceSetsPrefix + version + "/"
+
+	files, err := getFiles(ctx, prefix)
+	if err != nil {
+		return resourceSet{}, err
+	}
+
+	excludes, err := getExcludes(ctx, prefix)
+	if err != nil {
+		return resourceSet{}, err
+	}
+
+	set = resourceSet{files: files, excludes: excludes, loaded: time.Now()}
+	if len(files) != 0 || excludes != nil {
+		set.prefix = prefix
+	}
+
+	resourceSets.Lock()
+	defer resourceSets.Unlock()
+
+	if len(resourceSets.entries) >= maxResourceSets {
+		clear(resourceSets.entries)
+	}
+
+	resourceSets.entries[version] = set
+
+	return set, nil
+}
+
+// getExcludes reads the list of base keys hidden by an overlay, one per line.
+// Keys ending in a slash exclude everything below them. The list is nil if
+// the overlay has no exclude object, and empty if it has one with no keys.
+func getExcludes(ctx context.Context, set string) ([]string, error) {
+	f, _, err := storage.Open(ctx, strings.TrimSuffix(set, "/")+".exclude")
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	defer f.Close()
+
+	excludes := []string{}
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		excludes = append(excludes, line)
+	}
+
+	return excludes, s.Err()
+}
+
+func isExcluded(excludes []string, key string) bool {
+	for _, e := range excludes {
+		if key == e || (strings.HasSuffix(e, "/") && strings.HasPrefix(key, e)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getResources lists the resource tree for the given version, which is the
+// base tree with the version's overlay (if any) applied on top
+func getResources(ctx context.Context, version string) ([]Object, error) {
+	files, err := getFiles(ctx, resourcesPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := getResourceSet(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	if set.prefix == "" {
+		return files, nil
+	}
+
+	files = slices.DeleteFunc(files, func(o Object) bool {
+		if isExcluded(set.excludes, o.Key) {
+			return true
+		}
+
+		return slices.ContainsFunc(set.files, func(ov Object) bool { return ov.Key == o.Key })
+	})
+
+	files = append(files, set.files...)
+
+	slices.SortFunc(files, func(a, b Object) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return files, nil
+}
+
+// resolveResource returns the object key a resource should be served from for
+// the given version. ok is false if the version's overlay hides the resource.
+func resolveResource(ctx context.Context, version string, resource string) (key string, ok bool) {
+	base := resourcesPrefix + resource
+
+	set, err := getResourceSet(ctx, version)
+	if err != nil || set.prefix == "" {
+		return base, true
+	}
+
+	if slices.ContainsFunc(set.files, func(o Object) bool { return o.Key == resource }) {
+		return set.prefix + resource, true
+	}
+
+	if isExcluded(set.excludes, resource) {
+		return "", false
+	}
+
+	return base, true
+}
@@ -0,0 +1,132 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/patapancakes/betablock/storage"
+)
+
+func initStore(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for key, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(key))
+
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(p, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := storage.NewLocal(dir, storage.SymlinksRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage.Init(s)
+
+	resourceSets.Lock()
+	clear(resourceSets.entries)
+	resourceSets.Unlock()
+
+	return dir
+}
+
+func resourceKeys(t *testing.T, version string) []string {
+	files, err := getResources(context.Background(), version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, f := range files {
+		keys = append(keys, f.Key)
+	}
+
+	return keys
+}
+
+func TestResourceOverlays(t *testing.T) {
+	initStore(t, map[string]string{
+		"resources/sound/a.ogg":         "a",
+		"resources/sound/b.ogg":         "b",
+		"resources/music/c.ogg":         "c",
+		"resourcesets/b1.7/sound/a.ogg": "a for b1.7",
+		"resourcesets/a1.1.exclude":     "# only hides\nmusic/\n",
+	})
+
+	tests := []struct {
+		version  string
+		want     []string
+		resolved map[string]string // resource to key, empty if hidden
+	}{
+		{"", []string{"music/c.ogg", "sound/a.ogg", "sound/b.ogg"}, map[string]string{"sound/a.ogg": "resources/sound/a.ogg"}},
+		{"b1.7", []string{"music/c.ogg", "sound/a.ogg", "sound/b.ogg"}, map[string]string{"sound/a.ogg": "resourcesets/b1.7/sound/a.ogg", "sound/b.ogg": "resources/sound/b.ogg"}},
+		// an overlay with nothing but an exclude list still applies
+		{"a1.1", []string{"sound/a.ogg", "sound/b.ogg"}, map[string]string{"music/c.ogg": "", "sound/a.ogg": "resources/sound/a.ogg"}},
+		{"../resources", []string{"music/c.ogg", "sound/a.ogg", "sound/b.ogg"}, map[string]string{"sound/a.ogg": "resources/sound/a.ogg"}},
+	}
+
+	for _, tt := range tests {
+		keys := resourceKeys(t, tt.version)
+		if !slices.Equal(keys, tt.want) {
+			t.Errorf("version %q lists %q, want %q", tt.version, keys, tt.want)
+		}
+
+		for resource, want := range tt.resolved {
+			key, ok := resolveResource(context.Background(), tt.version, resource)
+			if key != want || ok != (want != "") {
+				t.Errorf("version %q resolves %s to %q, %t, want %q", tt.version, resource, key, ok, want)
+			}
+		}
+	}
+}
+
+func TestResourceSetCache(t *testing.T) {
+	dir := initStore(t, map[string]string{
+		"resources/a.ogg":           "a",
+		"resourcesets/b1.7.exclude": "a.ogg\n",
+	})
+
+	keys := resourceKeys(t, "b1.7")
+	if len(keys) != 0 {
+		t.Fatalf("b1.7 lists %q, want nothing", keys)
+	}
+
+	// the overlay is remembered rather than read on every request
+	err := os.Remove(filepath.Join(dir, "resourcesets", "b1.7.exclude"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys = resourceKeys(t, "b1.7")
+	if len(keys) != 0 {
+		t.Errorf("b1.7 lists %q after the exclude list was removed, want the cached overlay", keys)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package challenge
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// Challenge is a human verification check attached to forms
+type Challenge interface {
+	// Head returns markup for the page head, such as the provider's script
+	Head() template.HTML
+
+	// Widget returns markup placed inside each protected form
+	Widget() template.HTML
+
+	// Verify reports whether the request solved the challenge
+	Verify(r *http.Request) (bool, error)
+}
+
+var challenge Challenge
+
+func Init(c Challenge) {
+	challenge = c
+}
+
+// Enabled reports whether a challenge is configured
+func Enabled() bool {
+	return challenge != nil
+}
+
+func Head() template.HTML {
+	if challenge == nil {
+		return ""
+	}
+
+	return challenge.Head()
+}
+
+func Widget() template.HTML {
+	if challenge == nil {
+		return ""
+	}
+
+	return challenge.Widget()
+}
+
+// Verify checks the request against the configured challenge, every request
+// passes if there isn't one
+func Verify(r *http.Request) (bool, error) {
+	if challenge == nil {
+		return true, nil
+	}
+
+	return challenge.Verify(r)
+}
+
+// Handler serves fresh puzzles for challenges that issue their own, so forms
+// that stay on the page after submitting can get a new one
+func Handler(w http.ResponseWriter, r *http.Request) {
+	h, ok := challenge.(http.Handler)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.ServeHTTP(w, r)
+}
+
+// Policy reports whether a request has to pass the challenge
+type Policy func(r *http.Request) bool
+
+// Posts requires the challenge for every form submission
+func Posts(r *http.Request) bool {
+	return r.Method == "POST"
+}
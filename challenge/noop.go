@@ -0,0 +1,39 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package challenge
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// Noop passes every request, for exercising protected forms in tests
+type Noop struct{}
+
+func (Noop) Head() template.HTML {
+	return ""
+}
+
+func (Noop) Widget() template.HTML {
+	return ""
+}
+
+func (Noop) Verify(r *http.Request) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,218 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// puzzles have to be solved and submitted within this long
+const powLifetime = 10 * time.Minute
+
+// more than this many bits would take a browser far too long to find
+const maxDifficulty = 32
+
+// ProofOfWork makes the browser find a hash with a number of leading zero
+// bits, which costs a person a moment and a bot farm a lot of cpu time.
+// Puzzles are signed so nothing is stored until one is solved, solutions are
+// remembered until they expire so each puzzle can only be used once.
+type ProofOfWork struct {
+	key        []byte
+	difficulty int
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewProofOfWork returns a proof-of-work challenge requiring difficulty
+// leading zero bits, between 1 and 32. Puzzles are signed with key, a random
+// one is used if it's empty but then puzzles don't carry over restarts or
+// other servers.
+func NewProofOfWork(key []byte, difficulty int) (*ProofOfWork, error) {
+	if difficulty < 1 || difficulty > maxDifficulty {
+		return nil, fmt.Errorf("difficulty must be between 1 and %d, got %d", maxDifficulty, difficulty)
+	}
+
+	if len(key) == 0 {
+		key = make([]byte, 32)
+
+		_, err := rand.Read(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ProofOfWork{key: key, difficulty: difficulty, used: make(map[string]time.Time)}, nil
+}
+
+// sign returns the signature for a puzzle
+func (c *ProofOfWork) sign(puzzle string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(puzzle))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issue returns a new signed puzzle
+func (c *ProofOfWork) issue() string {
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint64(b, uint64(time.Now().Add(powLifetime).Unix()))
+	rand.Read(b[8:])
+
+	puzzle := base64.RawURLEncoding.EncodeToString(b)
+
+	return puzzle + "." + c.sign(puzzle)
+}
+
+// zeroBits counts the leading zero bits of hash
+func zeroBits(hash []byte) int {
+	var n int
+	for _, b := range hash {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+
+		n += 8
+	}
+
+	return n
+}
+
+func (c *ProofOfWork) Head() template.HTML {
+	return template.HTML(`<script>
+			async function solveChallenge(el) {
+				const form = el.closest("form");
+				const buttons = form.querySelectorAll("[type=submit]");
+				buttons.forEach((b) => b.disabled = true);
+				el.querySelector("span").textContent = "Verifying your browser...";
+
+				const puzzle = el.querySelector("[name=pow-puzzle]").value;
+				const difficulty = parseInt(el.dataset.difficulty);
+				const encoder = new TextEncoder();
+				for (let n = 0; ; n++) {
+					const hash = new Uint8Array(await crypto.subtle.digest("SHA-256", encoder.encode(puzzle + ":" + n)));
+					let zeros = 0;
+					for (const b of hash) {
+						if (b != 0) {
+							zeros += Math.clz32(b) - 24;
+							break;
+						}
+						zeros += 8;
+					}
+					if (zeros >= difficulty) {
+						el.querySelector("[name=pow-solution]").value = n;
+						break;
+					}
+				}
+
+				el.querySelector("span").textContent = "Verified";
+				buttons.forEach((b) => b.disabled = false);
+			}
+			async function resetChallenge() {
+				for (const el of document.querySelectorAll(".pow")) {
+					const resp = await fetch("/challenge");
+					el.querySelector("[name=pow-puzzle]").value = await resp.text();
+					el.querySelector("[name=pow-solution]").value = "";
+					solveChallenge(el);
+				}
+			}
+			document.addEventListener("DOMContentLoaded", () => document.querySelectorAll(".pow").forEach(solveChallenge));
+		</script>
+		<style>
+			.pow { margin-bottom: 1rem; }
+		</style>`)
+}
+
+func (c *ProofOfWork) Widget() template.HTML {
+	return template.HTML(`<div class="pow" data-difficulty="` + strconv.Itoa(c.difficulty) + `">
+		<input type="hidden" name="pow-puzzle" value="` + c.issue() + `">
+		<input type="hidden" name="pow-solution">
+		<span>Verification requires JavaScript</span>
+	</div>`)
+}
+
+func (c *ProofOfWork) Verify(r *http.Request) (bool, error) {
+	signed := r.FormValue("pow-puzzle")
+	solution := r.FormValue("pow-solution")
+
+	puzzle, signature, ok := strings.Cut(signed, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(c.sign(puzzle))) {
+		return false, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(puzzle)
+	if err != nil || len(b) != 24 {
+		return false, nil
+	}
+
+	expires := time.Unix(int64(binary.BigEndian.Uint64(b)), 0)
+	if time.Now().After(expires) {
+		return false, nil
+	}
+
+	_, err = strconv.ParseUint(solution, 10, 64)
+	if err != nil {
+		return false, nil
+	}
+
+	hash := sha256.Sum256([]byte(puzzle + "." + signature + ":" + solution))
+	if zeroBits(hash[:]) < c.difficulty {
+		return false, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// forget expired puzzles, they can't be replayed anymore anyway
+	now := time.Now()
+	for k, exp := range c.used {
+		if now.After(exp) {
+			delete(c.used, k)
+		}
+	}
+
+	_, ok = c.used[puzzle]
+	if ok {
+		return false, nil
+	}
+
+	c.used[puzzle] = expires
+
+	return true, nil
+}
+
+// ServeHTTP hands out a fresh puzzle
+func (c *ProofOfWork) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	w.Write([]byte(c.issue()))
+}
@@ -0,0 +1,164 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package challenge
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// solve finds a solution to signed the way the browser does
+func solve(signed string, difficulty int) string {
+	for n := 0; ; n++ {
+		solution := strconv.Itoa(n)
+
+		hash := sha256.Sum256([]byte(signed + ":" + solution))
+		if zeroBits(hash[:]) >= difficulty {
+			return solution
+		}
+	}
+}
+
+func verifyPoW(t *testing.T, c *ProofOfWork, signed string, solution string) bool {
+	t.Helper()
+
+	form := url.Values{"pow-puzzle": {signed}, "pow-solution": {solution}}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	ok, err := c.Verify(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ok
+}
+
+func TestProofOfWorkDifficulty(t *testing.T) {
+	for _, difficulty := range []int{-1, 0, 33, 256} {
+		_, err := NewProofOfWork(nil, difficulty)
+		if err == nil {
+			t.Errorf("difficulty %d was accepted", difficulty)
+		}
+	}
+
+	for _, difficulty := range []int{1, 32} {
+		_, err := NewProofOfWork(nil, difficulty)
+		if err != nil {
+			t.Errorf("difficulty %d: %s", difficulty, err)
+		}
+	}
+}
+
+func TestProofOfWorkVerify(t *testing.T) {
+	c, err := NewProofOfWork([]byte("key"), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := c.issue()
+	solution := solve(signed, 8)
+
+	// find a number that doesn't solve it
+	wrong := 0
+	for {
+		hash := sha256.Sum256([]byte(signed + ":" + strconv.Itoa(wrong)))
+		if zeroBits(hash[:]) < 8 {
+			break
+		}
+
+		wrong++
+	}
+
+	puzzle, signature, _ := strings.Cut(signed, ".")
+
+	other, err := NewProofOfWork([]byte("other key"), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foreign := other.issue()
+
+	for _, bad := range []struct {
+		name     string
+		signed   string
+		solution string
+	}{
+		{"missing", "", ""},
+		{"unsolved", signed, ""},
+		{"wrong solution", signed, strconv.Itoa(wrong)},
+		{"not a number", signed, "-" + solution},
+		{"unsigned", puzzle, solution},
+		{"bad signature", puzzle + "." + signature[1:], solution},
+		{"other key", foreign, solve(foreign, 8)},
+	} {
+		if verifyPoW(t, c, bad.signed, bad.solution) {
+			t.Errorf("%s puzzle passed", bad.name)
+		}
+	}
+
+	if !verifyPoW(t, c, signed, solution) {
+		t.Fatal("solved puzzle didn't pass")
+	}
+
+	// each puzzle only works once
+	if verifyPoW(t, c, signed, solution) {
+		t.Error("solved puzzle passed twice")
+	}
+}
+
+func TestProofOfWorkExpiry(t *testing.T) {
+	c, err := NewProofOfWork([]byte("key"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a puzzle that expired a second ago
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint64(b, uint64(time.Now().Add(-time.Second).Unix()))
+
+	puzzle := base64.RawURLEncoding.EncodeToString(b)
+	signed := puzzle + "." + c.sign(puzzle)
+
+	if verifyPoW(t, c, signed, solve(signed, 4)) {
+		t.Error("expired puzzle passed")
+	}
+
+	// the issued expiry is honoured
+	signed = c.issue()
+	puzzle, _, _ = strings.Cut(signed, ".")
+
+	b, err = base64.RawURLEncoding.DecodeString(puzzle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expires := time.Unix(int64(binary.BigEndian.Uint64(b)), 0)
+	if d := time.Until(expires); d <= powLifetime-time.Minute || d > powLifetime {
+		t.Errorf("puzzle expires in %s, want %s", d, powLifetime)
+	}
+}
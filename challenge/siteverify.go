@@ -0,0 +1,158 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package challenge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/patapancakes/betablock/proxy"
+)
+
+const (
+	TurnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+	HCaptchaVerifyURL  = "https://api.hcaptcha.com/siteverify"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+type siteverifyResponse struct {
+	// this is all we care about
+	Success bool `json:"success"`
+}
+
+// siteverify asks a captcha provider whether response is a valid solution.
+// Turnstile and hCaptcha share the same api.
+func siteverify(ctx context.Context, endpoint string, secret string, response string, remote string) (bool, error) {
+	form := url.Values{
+		"secret":   {secret},
+		"response": {response},
+		"remoteip": {remote},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("siteverify returned status %d", resp.StatusCode)
+	}
+
+	var sr siteverifyResponse
+	err = json.NewDecoder(resp.Body).Decode(&sr)
+	if err != nil {
+		return false, err
+	}
+
+	return sr.Success, nil
+}
+
+// Turnstile is Cloudflare's captcha
+type Turnstile struct {
+	siteKey   string
+	secretKey string
+	verifyURL string
+}
+
+// NewTurnstile returns a Turnstile challenge, verifyURL can be empty to use
+// Cloudflare's
+func NewTurnstile(siteKey string, secretKey string, verifyURL string) *Turnstile {
+	if verifyURL == "" {
+		verifyURL = TurnstileVerifyURL
+	}
+
+	return &Turnstile{siteKey: siteKey, secretKey: secretKey, verifyURL: verifyURL}
+}
+
+func (c *Turnstile) Head() template.HTML {
+	return `<link rel="preconnect" href="https://challenges.cloudflare.com">
+		<script src="https://challenges.cloudflare.com/turnstile/v0/api.js" async defer></script>
+		<script>function resetChallenge() { turnstile.reset(); }</script>
+		<style>
+			.cf-turnstile { line-height: 1; border: 1rem solid transparent; margin-bottom: 4rem; overflow: hidden; background: black; }
+			.cf-turnstile > div { margin: -2px; }
+		</style>`
+}
+
+func (c *Turnstile) Widget() template.HTML {
+	return template.HTML(`<div class="cf-turnstile" data-size="flexible" data-sitekey="` + template.HTMLEscapeString(c.siteKey) + `"></div>`)
+}
+
+func (c *Turnstile) Verify(r *http.Request) (bool, error) {
+	response := r.FormValue("cf-turnstile-response")
+	if response == "" {
+		return false, nil
+	}
+
+	return siteverify(r.Context(), c.verifyURL, c.secretKey, response, proxy.ClientIP(r))
+}
+
+// HCaptcha is hCaptcha's captcha
+type HCaptcha struct {
+	siteKey   string
+	secretKey string
+	verifyURL string
+}
+
+// NewHCaptcha returns an hCaptcha challenge, verifyURL can be empty to use
+// hCaptcha's
+func NewHCaptcha(siteKey string, secretKey string, verifyURL string) *HCaptcha {
+	if verifyURL == "" {
+		verifyURL = HCaptchaVerifyURL
+	}
+
+	return &HCaptcha{siteKey: siteKey, secretKey: secretKey, verifyURL: verifyURL}
+}
+
+func (c *HCaptcha) Head() template.HTML {
+	return `<script src="https://js.hcaptcha.com/1/api.js" async defer></script>
+		<script>function resetChallenge() { hcaptcha.reset(); }</script>
+		<style>
+			.h-captcha { margin-bottom: 1rem; }
+		</style>`
+}
+
+func (c *HCaptcha) Widget() template.HTML {
+	return template.HTML(`<div class="h-captcha" data-theme="dark" data-sitekey="` + template.HTMLEscapeString(c.siteKey) + `"></div>`)
+}
+
+func (c *HCaptcha) Verify(r *http.Request) (bool, error) {
+	response := r.FormValue("h-captcha-response")
+	if response == "" {
+		return false, nil
+	}
+
+	return siteverify(r.Context(), c.verifyURL, c.secretKey, response, proxy.ClientIP(r))
+}
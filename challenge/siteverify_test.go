@@ -0,0 +1,107 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package challenge
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeSiteverify stands in for a provider at /, accepting only the response
+// "solved" under the secret "secret"
+func fakeSiteverify(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method != "POST" || r.FormValue("remoteip") == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if r.FormValue("response") == "broken" {
+			w.Write([]byte("not json"))
+			return
+		}
+
+		success := r.FormValue("secret") == "secret" && r.FormValue("response") == "solved"
+		fmt.Fprintf(w, `{"success": %t, "error-codes": []}`, success)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestSiteverify(t *testing.T) {
+	s := fakeSiteverify(t)
+
+	for _, p := range []struct {
+		name  string
+		new   func(secret string) Challenge
+		field string
+	}{
+		{"turnstile", func(secret string) Challenge { return NewTurnstile("site", secret, s.URL) }, "cf-turnstile-response"},
+		{"hcaptcha", func(secret string) Challenge { return NewHCaptcha("site", secret, s.URL) }, "h-captcha-response"},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			for _, c := range []struct {
+				secret   string
+				response string
+				want     bool
+				err      bool
+			}{
+				{"secret", "solved", true, false},
+				{"secret", "unsolved", false, false},
+				{"secret", "", false, false},
+				{"wrong", "solved", false, false},
+				{"secret", "broken", false, true},
+			} {
+				form := url.Values{p.field: {c.response}}
+
+				r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+				ok, err := p.new(c.secret).Verify(r)
+				if ok != c.want || (err != nil) != c.err {
+					t.Errorf("secret %q response %q: got %t, %v", c.secret, c.response, ok, err)
+				}
+			}
+		})
+	}
+
+	// a provider that's down is an error rather than a failed challenge
+	down := NewTurnstile("site", "secret", s.URL+"/missing")
+
+	form := url.Values{"cf-turnstile-response": {"solved"}}
+	r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	_, err := down.Verify(r)
+	if err == nil {
+		t.Error("404 from the provider wasn't an error")
+	}
+}
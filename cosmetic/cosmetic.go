@@ -0,0 +1,168 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cosmetic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+
+	"github.com/patapancakes/betablock/storage"
+)
+
+// maxDimension is the largest width or height of any cosmetic, a cape
+// scaled up 4 times
+const maxDimension = 64 * 4
+
+type Kind string
+
+const (
+	Skin Kind = "skin"
+	Cape Kind = "cape"
+)
+
+// Key returns the storage key clients fetch the cosmetic from
+func Key(kind Kind, username string) string {
+	if kind == Cape {
+		return "capes/" + username + ".png"
+	}
+
+	return "skins/" + username + ".png"
+}
+
+// OriginalKey returns the storage key of the cosmetic as it was uploaded,
+// before any conversion for legacy clients
+func OriginalKey(kind Kind, username string) string {
+	return "originals/" + Key(kind, username)
+}
+
+// Publish stores a cosmetic for username in every variant clients need
+func Publish(ctx context.Context, kind Kind, username string, img image.Image) error {
+	defer Invalidate(username)
+
+	if kind == Skin {
+		err := put(ctx, OriginalKey(kind, username), img)
+		if err != nil {
+			return err
+		}
+
+		img = ToLegacy(img)
+	}
+
+	return put(ctx, Key(kind, username), img)
+}
+
+// Decode decodes a PNG image, checking its dimensions first so a small file
+// can't decode into a huge image
+func Decode(r io.ReadSeeker) (image.Image, error) {
+	config, err := png.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, ErrTooLarge
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return png.Decode(r)
+}
+
+func put(ctx context.Context, key string, img image.Image) error {
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, img)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, key, buf, int64(buf.Len()))
+}
+
+// Remove deletes every variant of username's published cosmetic
+func Remove(ctx context.Context, kind Kind, username string) error {
+	defer Invalidate(username)
+
+	err := storage.Delete(ctx, OriginalKey(kind, username))
+	if err != nil {
+		return err
+	}
+
+	return storage.Delete(ctx, Key(kind, username))
+}
+
+// Copy publishes username's cosmetic under another username as well, for
+// moving it when the account is renamed
+func Copy(ctx context.Context, kind Kind, username string, to string) error {
+	defer Invalidate(to)
+
+	for _, key := range []func(Kind, string) string{OriginalKey, Key} {
+		err := copyObject(ctx, key(kind, username), key(kind, to))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Move publishes username's cosmetic under another username instead, for
+// when the account is renamed. A change of case goes through a temporary key
+// since both names can be the same object in stores that ignore case.
+func Move(ctx context.Context, kind Kind, username string, to string) error {
+	if strings.EqualFold(username, to) {
+		// usernames can't contain dots, so nobody owns this key
+		tmp := username + ".moving"
+
+		err := Move(ctx, kind, username, tmp)
+		if err != nil {
+			return err
+		}
+
+		return Move(ctx, kind, tmp, to)
+	}
+
+	err := Copy(ctx, kind, username, to)
+	if err != nil {
+		return err
+	}
+
+	return Remove(ctx, kind, username)
+}
+
+func copyObject(ctx context.Context, from string, to string) error {
+	f, info, err := storage.Open(ctx, from)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	defer f.Close()
+
+	return storage.Put(ctx, to, f, info.Size)
+}
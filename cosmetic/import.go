@@ -0,0 +1,205 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cosmetic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/patapancakes/betablock/storage"
+)
+
+const (
+	maxFetchSize      = 1024 * 16 // same as uploads
+	maxFetchRedirects = 3
+	fetchTimeout      = 5 * time.Second
+)
+
+var (
+	ErrForbiddenURL     = errors.New("url scheme isn't allowed")
+	ErrForbiddenAddress = errors.New("address isn't publicly routable")
+	ErrTooLarge         = errors.New("image is too large")
+	ErrNotPNG           = errors.New("response isn't a png image")
+)
+
+// ranges that aren't covered by the netip.Addr helpers but still aren't
+// reachable on the public internet
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, p := range reservedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isFetchable reports whether images may be fetched from the address, which
+// has to be on the public internet and on one of the default web ports
+func isFetchable(ap netip.AddrPort) bool {
+	return (ap.Port() == 80 || ap.Port() == 443) && isPublicAddr(ap.Addr())
+}
+
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrForbiddenURL
+	}
+
+	return nil
+}
+
+// newFetchClient returns a client that only connects to addresses allowed by
+// allow. The check runs on every connection after name resolution, so it
+// also covers redirects and DNS records that change between lookups.
+func newFetchClient(allow func(netip.AddrPort) bool) *http.Client {
+	return &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			Proxy: nil,
+			DialContext: (&net.Dialer{
+				Timeout: fetchTimeout,
+				Control: func(network string, address string, c syscall.RawConn) error {
+					ap, err := netip.ParseAddrPort(address)
+					if err != nil {
+						return err
+					}
+					if !allow(ap) {
+						return ErrForbiddenAddress
+					}
+
+					return nil
+				},
+			}).DialContext,
+			TLSHandshakeTimeout:    fetchTimeout,
+			ResponseHeaderTimeout:  fetchTimeout,
+			MaxResponseHeaderBytes: 1024 * 16,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxFetchRedirects {
+				return errors.New("too many redirects")
+			}
+
+			return checkURL(req.URL)
+		},
+	}
+}
+
+var fetchClient = newFetchClient(isFetchable)
+
+// Fetch downloads and decodes a PNG image from a remote URL, refusing to
+// connect to anything that isn't on the public internet
+func Fetch(ctx context.Context, rawURL string) (image.Image, error) {
+	return fetch(ctx, fetchClient, rawURL)
+}
+
+func fetch(ctx context.Context, client *http.Client, rawURL string) (image.Image, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "image/png")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "image/png" {
+		return nil, ErrNotPNG
+	}
+
+	if resp.ContentLength > maxFetchSize {
+		return nil, ErrTooLarge
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxFetchSize {
+		return nil, ErrTooLarge
+	}
+
+	return Decode(bytes.NewReader(b))
+}
+
+// LoadPublished decodes username's current cosmetic, preferring the
+// original upload over the variant converted for legacy clients
+func LoadPublished(ctx context.Context, kind Kind, username string) (image.Image, error) {
+	f, _, err := storage.Open(ctx, OriginalKey(kind, username))
+	if errors.Is(err, storage.ErrNotExist) {
+		f, _, err = storage.Open(ctx, Key(kind, username))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return Decode(f)
+}
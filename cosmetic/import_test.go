@@ -0,0 +1,249 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cosmetic
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w int, h int) []byte {
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, image.NewNRGBA(image.Rect(0, 0, w, h)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// hugePNG returns a tiny PNG whose header claims it's far larger than any
+// cosmetic, which only decodes into that size if nothing checks first
+func hugePNG(t *testing.T) []byte {
+	b := encodePNG(t, 1, 1)
+
+	// the IHDR chunk follows the 8 byte signature, its data starts after the
+	// length and type
+	binary.BigEndian.PutUint32(b[16:], 30000)
+	binary.BigEndian.PutUint32(b[20:], 30000)
+	binary.BigEndian.PutUint32(b[29:], crc32.ChecksumIEEE(b[12:29]))
+
+	return b
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"1.1.1.1", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if isPublicAddr(netip.MustParseAddr(tt.addr)) != tt.public {
+			t.Errorf("isPublicAddr(%s) = %t, want %t", tt.addr, !tt.public, tt.public)
+		}
+	}
+
+	for _, ap := range []string{"1.1.1.1:80", "1.1.1.1:443"} {
+		if !isFetchable(netip.MustParseAddrPort(ap)) {
+			t.Errorf("%s isn't fetchable", ap)
+		}
+	}
+	for _, ap := range []string{"1.1.1.1:22", "1.1.1.1:8080", "127.0.0.1:80"} {
+		if isFetchable(netip.MustParseAddrPort(ap)) {
+			t.Errorf("%s is fetchable", ap)
+		}
+	}
+}
+
+// allowPorts returns an address check that only lets through the ports of
+// the given test servers
+func allowPorts(t *testing.T, servers ...*httptest.Server) func(netip.AddrPort) bool {
+	var ports []uint16
+	for _, s := range servers {
+		u, err := url.Parse(s.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		port, err := strconv.Atoi(u.Port())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ports = append(ports, uint16(port))
+	}
+
+	return func(ap netip.AddrPort) bool {
+		for _, p := range ports {
+			if ap.Port() == p {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func TestFetch(t *testing.T) {
+	skin := encodePNG(t, 64, 32)
+
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(skin)
+	}))
+	defer elsewhere.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/skin.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(skin)
+	})
+	mux.HandleFunc("/charset.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png; charset=binary")
+		w.Write(skin)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(skin)
+	})
+	mux.HandleFunc("/missing.png", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/declared.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", strconv.Itoa(maxFetchSize+1))
+		w.Write(make([]byte, maxFetchSize+1))
+	})
+	mux.HandleFunc("/streamed.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.(http.Flusher).Flush() // no Content-Length, so the size is only known by reading
+		w.Write(make([]byte, maxFetchSize+1))
+	})
+	mux.HandleFunc("/huge.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(hugePNG(t))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/skin.png", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/ftp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/skin.png", http.StatusFound)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, elsewhere.URL+"/skin.png", http.StatusFound)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// the other server's address is off limits, like a private one would be
+	client := newFetchClient(allowPorts(t, srv))
+
+	tests := []struct {
+		path string
+		err  error // nil for any error if ok is false
+		ok   bool
+	}{
+		{"/skin.png", nil, true},
+		{"/charset.png", nil, true},
+		{"/redirect", nil, true},
+		{"/page.html", ErrNotPNG, false},
+		{"/missing.png", nil, false},
+		{"/declared.png", ErrTooLarge, false},
+		{"/streamed.png", ErrTooLarge, false},
+		{"/huge.png", ErrTooLarge, false},
+		{"/loop", nil, false},
+		{"/ftp", ErrForbiddenURL, false},
+		{"/elsewhere", ErrForbiddenAddress, false},
+	}
+
+	for _, tt := range tests {
+		img, err := fetch(context.Background(), client, srv.URL+tt.path)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("%s: %s", tt.path, err)
+				continue
+			}
+
+			if img.Bounds() != image.Rect(0, 0, 64, 32) {
+				t.Errorf("%s: decoded a %s image", tt.path, img.Bounds())
+			}
+
+			continue
+		}
+
+		if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+			t.Errorf("%s: returned %v, want %v", tt.path, err, tt.err)
+		}
+	}
+
+	_, err := fetch(context.Background(), client, "file:///etc/passwd")
+	if !errors.Is(err, ErrForbiddenURL) {
+		t.Errorf("file url returned %v, want ErrForbiddenURL", err)
+	}
+
+	// the real client refuses loopback test servers outright
+	_, err = Fetch(context.Background(), srv.URL+"/skin.png")
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("fetching from loopback returned %v, want ErrForbiddenAddress", err)
+	}
+}
+
+func TestDecodeDimensions(t *testing.T) {
+	_, err := Decode(bytes.NewReader(hugePNG(t)))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("decoding an oversized image returned %v, want ErrTooLarge", err)
+	}
+
+	img, err := Decode(bytes.NewReader(encodePNG(t, 256, 128)))
+	if err != nil || img.Bounds().Dx() != 256 {
+		t.Errorf("decoding the largest cape size returned %v", err)
+	}
+}
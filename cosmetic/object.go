@@ -0,0 +1,96 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cosmetic
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/png"
+	"regexp"
+
+	"github.com/patapancakes/betablock/storage"
+)
+
+var ErrInvalidHash = errors.New("invalid object hash")
+
+var isValidHash = regexp.MustCompile("^[0-9a-f]{64}$").MatchString
+
+// ObjectKey returns the storage key of the content addressed image with the
+// given hash
+func ObjectKey(hash string) string {
+	return "cosmetics/" + hash + ".png"
+}
+
+// Store saves img as a content addressed object and returns its hash.
+// Identical images are only stored once.
+func Store(ctx context.Context, img image.Image) (string, error) {
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, img)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	hash := hex.EncodeToString(sum[:])
+
+	_, err = storage.Stat(ctx, ObjectKey(hash))
+	if err == nil {
+		return hash, nil
+	}
+	if !errors.Is(err, storage.ErrNotExist) {
+		return "", err
+	}
+
+	err = storage.Put(ctx, ObjectKey(hash), buf, int64(buf.Len()))
+	if err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// Load decodes the content addressed image with the given hash
+func Load(ctx context.Context, hash string) (image.Image, error) {
+	if !isValidHash(hash) {
+		return nil, ErrInvalidHash
+	}
+
+	f, _, err := storage.Open(ctx, ObjectKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return png.Decode(f)
+}
+
+// Discard deletes the content addressed image with the given hash, callers
+// have to make sure nothing references it anymore
+func Discard(ctx context.Context, hash string) error {
+	if !isValidHash(hash) {
+		return ErrInvalidHash
+	}
+
+	return storage.Delete(ctx, ObjectKey(hash))
+}
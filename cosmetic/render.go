@@ -0,0 +1,242 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cosmetic
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"sync"
+
+	"github.com/patapancakes/betablock/storage"
+)
+
+type Render string
+
+const (
+	RenderFace Render = "face"
+	RenderBody Render = "body"
+	RenderCape Render = "cape"
+)
+
+var ErrUnknownRender = errors.New("unknown render")
+
+const maxCachedRenders = 4096
+
+type cachedRender struct {
+	source string // hash of the texture the render was made from
+	data   []byte
+	hash   string
+}
+
+// cached renders by username, then render and size
+var renders = struct {
+	sync.RWMutex
+	entries map[string]map[string]cachedRender
+	count   int
+}{entries: make(map[string]map[string]cachedRender)}
+
+// Invalidate drops every cached render for username
+func Invalidate(username string) {
+	renders.Lock()
+	defer renders.Unlock()
+
+	renders.count -= len(renders.entries[username])
+	delete(renders.entries, username)
+}
+
+// GetRender returns a PNG render of username's cosmetics, scaled so its
+// height is size pixels, along with the hex encoded MD5 hash of the PNG
+func GetRender(ctx context.Context, render Render, username string, size int) ([]byte, string, error) {
+	var key string
+	switch render {
+	case RenderFace, RenderBody:
+		key = OriginalKey(Skin, username)
+
+		// skins uploaded before originals were kept
+		_, err := storage.Stat(ctx, key)
+		if errors.Is(err, storage.ErrNotExist) {
+			key = Key(Skin, username)
+		}
+	case RenderCape:
+		key = Key(Cape, username)
+	default:
+		return nil, "", ErrUnknownRender
+	}
+
+	f, info, err := storage.Open(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer f.Close()
+
+	ck := string(render) + "/" + strconv.Itoa(size)
+
+	renders.RLock()
+	cached, ok := renders.entries[username][ck]
+	renders.RUnlock()
+
+	if ok && cached.source == info.Hash {
+		return cached.data, cached.hash, nil
+	}
+
+	texture, err := png.Decode(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var out image.Image
+	switch render {
+	case RenderFace:
+		out = renderFace(texture)
+	case RenderBody:
+		out = renderBody(texture)
+	case RenderCape:
+		out = renderCape(texture)
+	}
+
+	out = scale(out, out.Bounds().Dx()*size/out.Bounds().Dy(), size)
+
+	buf := new(bytes.Buffer)
+	err = png.Encode(buf, out)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sum := md5.Sum(buf.Bytes())
+	cached = cachedRender{source: info.Hash, data: buf.Bytes(), hash: hex.EncodeToString(sum[:])}
+
+	renders.Lock()
+	if renders.count >= maxCachedRenders {
+		clear(renders.entries)
+		renders.count = 0
+	}
+	if renders.entries[username] == nil {
+		renders.entries[username] = make(map[string]cachedRender)
+	}
+	if _, ok := renders.entries[username][ck]; !ok {
+		renders.count++
+	}
+	renders.entries[username][ck] = cached
+	renders.Unlock()
+
+	return cached.data, cached.hash, nil
+}
+
+// copyRect draws the src rectangle r of texture at dst in out, optionally
+// mirrored horizontally
+func copyRect(out draw.Image, dst image.Point, texture image.Image, r image.Rectangle, op draw.Op, mirror bool) {
+	b := texture.Bounds()
+	r = r.Add(b.Min)
+
+	if !mirror {
+		draw.Draw(out, image.Rectangle{Min: dst, Max: dst.Add(r.Size())}, texture, r.Min, op)
+		return
+	}
+
+	for x := range r.Dx() {
+		col := image.Rect(r.Max.X-1-x, r.Min.Y, r.Max.X-x, r.Max.Y)
+		draw.Draw(out, image.Rect(dst.X+x, dst.Y, dst.X+x+1, dst.Y+r.Dy()), texture, col.Min, op)
+	}
+}
+
+// renderFace renders the front of the head with the hat layer on top
+func renderFace(skin image.Image) image.Image {
+	out := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+
+	copyRect(out, image.Pt(0, 0), skin, image.Rect(8, 8, 16, 16), draw.Src, false)
+	copyRect(out, image.Pt(0, 0), skin, image.Rect(40, 8, 48, 16), draw.Over, false)
+
+	return out
+}
+
+// renderBody renders a flat view of the front of the player
+func renderBody(skin image.Image) image.Image {
+	out := image.NewNRGBA(image.Rect(0, 0, 16, 32))
+
+	modern := skin.Bounds().Dy() == 64
+
+	// first layer
+	copyRect(out, image.Pt(4, 0), skin, image.Rect(8, 8, 16, 16), draw.Src, false)   // head
+	copyRect(out, image.Pt(4, 8), skin, image.Rect(20, 20, 28, 32), draw.Src, false) // body
+	copyRect(out, image.Pt(0, 8), skin, image.Rect(44, 20, 48, 32), draw.Src, false) // right arm
+	copyRect(out, image.Pt(4, 20), skin, image.Rect(4, 20, 8, 32), draw.Src, false)  // right leg
+	if modern {
+		copyRect(out, image.Pt(12, 8), skin, image.Rect(36, 52, 40, 64), draw.Src, false) // left arm
+		copyRect(out, image.Pt(8, 20), skin, image.Rect(20, 52, 24, 64), draw.Src, false) // left leg
+	} else {
+		// legacy skins mirror the right limbs
+		copyRect(out, image.Pt(12, 8), skin, image.Rect(44, 20, 48, 32), draw.Src, true)
+		copyRect(out, image.Pt(8, 20), skin, image.Rect(4, 20, 8, 32), draw.Src, true)
+	}
+
+	// second layer
+	copyRect(out, image.Pt(4, 0), skin, image.Rect(40, 8, 48, 16), draw.Over, false) // hat
+	if modern {
+		copyRect(out, image.Pt(4, 8), skin, image.Rect(20, 36, 28, 48), draw.Over, false)  // jacket
+		copyRect(out, image.Pt(0, 8), skin, image.Rect(44, 36, 48, 48), draw.Over, false)  // right sleeve
+		copyRect(out, image.Pt(12, 8), skin, image.Rect(52, 52, 56, 64), draw.Over, false) // left sleeve
+		copyRect(out, image.Pt(4, 20), skin, image.Rect(4, 36, 8, 48), draw.Over, false)   // right pants leg
+		copyRect(out, image.Pt(8, 20), skin, image.Rect(4, 52, 8, 64), draw.Over, false)   // left pants leg
+	}
+
+	return out
+}
+
+// renderCape renders the outside of the cape
+func renderCape(cape image.Image) image.Image {
+	b := cape.Bounds()
+
+	// 22x17 capes are the used part of a 64x32 one, so they share a layout
+	unit := b.Dx() / 64
+	if b.Dx()*17 == b.Dy()*22 {
+		unit = b.Dx() / 22
+	}
+	if unit == 0 {
+		unit = 1
+	}
+
+	r := image.Rect(1*unit, 1*unit, 11*unit, 17*unit)
+
+	out := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	copyRect(out, image.Point{}, cape, r, draw.Src, false)
+
+	return out
+}
+
+// scale resizes img to w by h pixels using nearest neighbour sampling
+func scale(img image.Image, w int, h int) image.Image {
+	b := img.Bounds()
+
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			out.Set(x, y, img.At(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h))
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,296 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cosmetic
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/patapancakes/betablock/storage"
+)
+
+var (
+	red   = color.NRGBA{255, 0, 0, 255}
+	green = color.NRGBA{0, 255, 0, 255}
+	blue  = color.NRGBA{0, 0, 255, 255}
+)
+
+func nrgbaAt(img image.Image, x, y int) color.NRGBA {
+	return color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+}
+
+func TestRenderFace(t *testing.T) {
+	skin := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	skin.SetNRGBA(8, 8, red)
+	skin.SetNRGBA(9, 8, red)
+	skin.SetNRGBA(41, 8, green) // hat over the second pixel
+
+	face := renderFace(skin)
+	if face.Bounds() != image.Rect(0, 0, 8, 8) {
+		t.Fatalf("face bounds %v, want 8x8", face.Bounds())
+	}
+	if nrgbaAt(face, 0, 0) != red || nrgbaAt(face, 1, 0) != green {
+		t.Errorf("face starts with %v, %v, want %v, %v", nrgbaAt(face, 0, 0), nrgbaAt(face, 1, 0), red, green)
+	}
+}
+
+func TestRenderBody(t *testing.T) {
+	// right arm front, outermost column green and innermost blue
+	legacy := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	legacy.SetNRGBA(44, 20, green)
+	legacy.SetNRGBA(47, 20, blue)
+	legacy.SetNRGBA(20, 20, red) // body
+
+	body := renderBody(legacy)
+	if body.Bounds() != image.Rect(0, 0, 16, 32) {
+		t.Fatalf("body bounds %v, want 16x32", body.Bounds())
+	}
+
+	want := map[image.Point]color.NRGBA{
+		{0, 8}:  green,
+		{3, 8}:  blue,
+		{4, 8}:  red,
+		{12, 8}: blue, // mirrored left arm
+		{15, 8}: green,
+	}
+	for p, c := range want {
+		if nrgbaAt(body, p.X, p.Y) != c {
+			t.Errorf("legacy body pixel %v is %v, want %v", p, nrgbaAt(body, p.X, p.Y), c)
+		}
+	}
+
+	// modern skins have their own left limbs and a second layer
+	modern := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	modern.SetNRGBA(44, 20, green)
+	modern.SetNRGBA(36, 52, red)  // left arm
+	modern.SetNRGBA(20, 20, red)  // body
+	modern.SetNRGBA(20, 36, blue) // jacket
+	modern.SetNRGBA(4, 52, green) // left pants leg
+
+	body = renderBody(modern)
+
+	want = map[image.Point]color.NRGBA{
+		{0, 8}:  green,
+		{12, 8}: red,
+		{15, 8}: {},
+		{4, 8}:  blue,
+		{8, 20}: green,
+	}
+	for p, c := range want {
+		if nrgbaAt(body, p.X, p.Y) != c {
+			t.Errorf("modern body pixel %v is %v, want %v", p, nrgbaAt(body, p.X, p.Y), c)
+		}
+	}
+}
+
+func TestRenderCape(t *testing.T) {
+	tests := []struct {
+		w, h int
+		unit int
+	}{
+		{64, 32, 1},
+		{22, 17, 1},
+		{128, 64, 2},
+		{88, 68, 4},
+	}
+
+	for _, tt := range tests {
+		cape := image.NewNRGBA(image.Rect(0, 0, tt.w, tt.h))
+		cape.SetNRGBA(tt.unit, tt.unit, red)     // top left of the outside
+		cape.SetNRGBA(tt.unit-1, tt.unit, green) // edge, not rendered
+
+		out := renderCape(cape)
+		if out.Bounds() != image.Rect(0, 0, 10*tt.unit, 16*tt.unit) {
+			t.Errorf("%dx%d: render bounds %v, want %dx%d", tt.w, tt.h, out.Bounds(), 10*tt.unit, 16*tt.unit)
+			continue
+		}
+		if nrgbaAt(out, 0, 0) != red {
+			t.Errorf("%dx%d: render starts with %v, want %v", tt.w, tt.h, nrgbaAt(out, 0, 0), red)
+		}
+	}
+}
+
+func putPNG(t *testing.T, key string, img image.Image) {
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.Put(context.Background(), key, buf, int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func initRenders(t *testing.T) {
+	s, err := storage.NewLocal(t.TempDir(), storage.SymlinksRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage.Init(s)
+
+	renders.Lock()
+	clear(renders.entries)
+	renders.count = 0
+	renders.Unlock()
+}
+
+func TestGetRender(t *testing.T) {
+	initRenders(t)
+
+	ctx := context.Background()
+
+	// skins uploaded before originals were kept are rendered from the legacy copy
+	skin := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	skin.SetNRGBA(8, 8, red)
+	putPNG(t, Key(Skin, "Notch"), skin)
+
+	data, hash, err := GetRender(ctx, RenderFace, "Notch", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := md5.Sum(data)
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("render hash %s isn't the MD5 of the PNG", hash)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 16, 16) || nrgbaAt(img, 1, 1) != red || nrgbaAt(img, 2, 2).A != 0 {
+		t.Errorf("face render is %v with %v, %v, want 16x16 with the red pixel scaled up", img.Bounds(), nrgbaAt(img, 1, 1), nrgbaAt(img, 2, 2))
+	}
+
+	// the original takes precedence once there is one
+	skin.SetNRGBA(8, 8, green)
+	putPNG(t, OriginalKey(Skin, "Notch"), skin)
+
+	data, _, err = GetRender(ctx, RenderFace, "Notch", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err = png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nrgbaAt(img, 0, 0) != green {
+		t.Errorf("face render starts with %v after the original was added, want %v", nrgbaAt(img, 0, 0), green)
+	}
+
+	_, _, err = GetRender(ctx, RenderCape, "Notch", 16)
+	if !errors.Is(err, storage.ErrNotExist) {
+		t.Errorf("rendering a missing cape returned %v, want ErrNotExist", err)
+	}
+
+	_, _, err = GetRender(ctx, "feet", "Notch", 16)
+	if !errors.Is(err, ErrUnknownRender) {
+		t.Errorf("rendering feet returned %v, want ErrUnknownRender", err)
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	initRenders(t)
+
+	ctx := context.Background()
+
+	skin := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	putPNG(t, OriginalKey(Skin, "Notch"), skin)
+
+	_, first, err := GetRender(ctx, RenderFace, "Notch", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cached entries are served as long as the texture is unchanged
+	renders.Lock()
+	entry := renders.entries["Notch"]["face/8"]
+	entry.hash = "cached"
+	renders.entries["Notch"]["face/8"] = entry
+	renders.Unlock()
+
+	_, hash, err := GetRender(ctx, RenderFace, "Notch", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "cached" {
+		t.Errorf("unchanged texture rendered to %s, want the cached render", hash)
+	}
+
+	// a new texture is rendered again, even without invalidating
+	skin.SetNRGBA(8, 8, red)
+	putPNG(t, OriginalKey(Skin, "Notch"), skin)
+
+	_, hash, err = GetRender(ctx, RenderFace, "Notch", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "cached" || hash == first {
+		t.Errorf("changed texture rendered to %s, want a new render", hash)
+	}
+
+	_, _, err = GetRender(ctx, RenderBody, "Notch", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renders.RLock()
+	count := renders.count
+	renders.RUnlock()
+	if count != 2 {
+		t.Errorf("%d renders cached, want 2", count)
+	}
+
+	Invalidate("Notch")
+
+	renders.RLock()
+	count, cached := renders.count, len(renders.entries["Notch"])
+	renders.RUnlock()
+	if count != 0 || cached != 0 {
+		t.Errorf("%d renders cached, %d for Notch after invalidating, want none", count, cached)
+	}
+
+	// a full cache is emptied rather than growing
+	renders.Lock()
+	renders.count = maxCachedRenders
+	renders.Unlock()
+
+	_, _, err = GetRender(ctx, RenderFace, "Notch", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renders.RLock()
+	count = renders.count
+	renders.RUnlock()
+	if count != 1 {
+		t.Errorf("%d renders cached after filling up, want 1", count)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cosmetic
+
+import (
+	"image"
+	"image/draw"
+)
+
+// second layer areas of a 64x64 skin and the first layer areas of the 64x32
+// format they're merged onto
+var legacyOverlays = []struct {
+	from image.Rectangle
+	to   image.Point
+}{
+	{image.Rect(0, 32, 16, 48), image.Pt(0, 16)},   // right leg
+	{image.Rect(16, 32, 40, 48), image.Pt(16, 16)}, // body
+	{image.Rect(40, 32, 56, 48), image.Pt(40, 16)}, // right arm
+}
+
+// ToLegacy converts a skin to the 64x32 format understood by Alpha and Beta
+// clients. 64x64 skins have their second layer merged onto the first and the
+// separate left limbs dropped, as legacy clients mirror the right ones.
+func ToLegacy(img image.Image) image.Image {
+	b := img.Bounds()
+	if b.Dx() != 64 || b.Dy() != 64 {
+		return img
+	}
+
+	legacy := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+
+	// head, hat and first layer of the right limbs and body
+	draw.Draw(legacy, legacy.Bounds(), img, b.Min, draw.Src)
+
+	for _, o := range legacyOverlays {
+		r := image.Rectangle{Min: o.to, Max: o.to.Add(o.from.Size())}
+		draw.Draw(legacy, r, img, b.Min.Add(o.from.Min), draw.Over)
+	}
+
+	return legacy
+}
@@ -0,0 +1,99 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cosmetic
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestToLegacy(t *testing.T) {
+	var (
+		red   = color.NRGBA{255, 0, 0, 255}
+		green = color.NRGBA{0, 255, 0, 255}
+		blue  = color.NRGBA{0, 0, 255, 128}
+		hat   = color.NRGBA{10, 20, 30, 100}
+	)
+
+	// offset so conversion has to respect the image's bounds
+	img := image.NewNRGBA(image.Rect(10, 10, 74, 74))
+	set := func(x, y int, c color.NRGBA) { img.SetNRGBA(10+x, 10+y, c) }
+
+	set(8, 8, red)     // head
+	set(40, 8, hat)    // hat, kept as is for the client to draw over the head
+	set(20, 20, red)   // body
+	set(20, 36, green) // body overlay, replaces the body pixel
+	set(21, 20, red)   // body
+	set(22, 20, red)   // body, under a half transparent overlay
+	set(22, 36, blue)
+	set(4, 20, red)    // right leg, under a transparent overlay
+	set(44, 20, red)   // right arm
+	set(44, 36, green) // right arm overlay
+	set(20, 52, green) // left leg, dropped
+
+	legacy := ToLegacy(img)
+
+	if legacy.Bounds() != image.Rect(0, 0, 64, 32) {
+		t.Fatalf("converted bounds %v, want 64x32", legacy.Bounds())
+	}
+
+	at := func(x, y int) color.NRGBA {
+		return color.NRGBAModel.Convert(legacy.At(x, y)).(color.NRGBA)
+	}
+
+	want := map[image.Point]color.NRGBA{
+		{8, 8}:   red,
+		{40, 8}:  hat,
+		{20, 20}: green,
+		{21, 20}: red,
+		{4, 20}:  red,
+		{44, 20}: green,
+	}
+	for p, c := range want {
+		if at(p.X, p.Y) != c {
+			t.Errorf("pixel %v is %v, want %v", p, at(p.X, p.Y), c)
+		}
+	}
+
+	// blended rather than replaced
+	blended := at(22, 20)
+	if blended.A != 255 || blended.R == 0 || blended.R == 255 || blended.B == 0 || blended.B == 255 {
+		t.Errorf("half transparent overlay gave %v, want a blend of %v and %v", blended, red, blue)
+	}
+
+	// the left leg and everything else that wasn't set stays transparent
+	for y := 16; y < 32; y++ {
+		for x := 0; x < 56; x++ {
+			_, ok := want[image.Pt(x, y)]
+			if ok || image.Pt(x, y) == image.Pt(22, 20) {
+				continue
+			}
+			if at(x, y).A != 0 {
+				t.Errorf("pixel (%d, %d) is %v, want transparent", x, y, at(x, y))
+			}
+		}
+	}
+
+	// legacy skins are already in the right format
+	old := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	if ToLegacy(old) != image.Image(old) {
+		t.Error("a 64x32 skin was converted")
+	}
+}
@@ -0,0 +1,195 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cosmetic
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"slices"
+	"strings"
+)
+
+// Region is a named part of a skin made up of the faces of one box
+type Region struct {
+	Name  string
+	Faces []image.Rectangle
+}
+
+func box(name string, x, y, w, h, d int) Region {
+	return Region{Name: name, Faces: []image.Rectangle{
+		image.Rect(x+d, y, x+d+w, y+d),       // top
+		image.Rect(x+d+w, y, x+d+w+w, y+d),   // bottom
+		image.Rect(x, y+d, x+d+w+d+w, y+d+h), // sides, front and back
+	}}
+}
+
+// first layer regions, which legacy clients render without blending
+var skinBaseRegions = []Region{
+	box("head", 0, 0, 8, 8, 8),
+	box("body", 16, 16, 8, 12, 4),
+	box("right arm", 40, 16, 4, 12, 4),
+	box("right leg", 0, 16, 4, 12, 4),
+}
+
+// first layer regions only present in 64x64 skins
+var skinModernBaseRegions = []Region{
+	box("left leg", 16, 48, 4, 12, 4),
+	box("left arm", 32, 48, 4, 12, 4),
+}
+
+var skinOverlayRegions = []Region{
+	box("hat", 32, 0, 8, 8, 8),
+}
+
+// second layer regions only present in 64x64 skins
+var skinModernOverlayRegions = []Region{
+	box("jacket", 16, 32, 8, 12, 4),
+	box("right sleeve", 40, 32, 4, 12, 4),
+	box("right pants leg", 0, 32, 4, 12, 4),
+	box("left pants leg", 0, 48, 4, 12, 4),
+	box("left sleeve", 48, 48, 4, 12, 4),
+}
+
+// ValidationError describes why an image can't be used as a cosmetic,
+// including the regions of it that failed validation, if any
+type ValidationError struct {
+	Reason  string
+	Regions []Region
+
+	img image.Image
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Regions) == 0 {
+		return e.Reason
+	}
+
+	var names []string
+	for _, r := range e.Regions {
+		names = append(names, r.Name)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Reason, strings.Join(names, ", "))
+}
+
+// Preview renders the image enlarged with the failed regions highlighted
+func (e *ValidationError) Preview() ([]byte, error) {
+	const scale = 4
+
+	b := e.img.Bounds()
+
+	preview := image.NewNRGBA(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale))
+	for y := range b.Dy() {
+		for x := range b.Dx() {
+			draw.Draw(preview, image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale), &image.Uniform{e.img.At(b.Min.X+x, b.Min.Y+y)}, image.Point{}, draw.Src)
+		}
+	}
+
+	highlight := &image.Uniform{color.NRGBA{R: 255, A: 128}}
+	for _, r := range e.Regions {
+		for _, f := range r.Faces {
+			draw.Draw(preview, image.Rect(f.Min.X*scale, f.Min.Y*scale, f.Max.X*scale, f.Max.Y*scale), highlight, image.Point{}, draw.Over)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, preview)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Validate checks that img is usable as the given kind of cosmetic
+func Validate(kind Kind, img image.Image) error {
+	if kind == Cape {
+		return validateCape(img)
+	}
+
+	return validateSkin(img)
+}
+
+func validateSkin(img image.Image) error {
+	b := img.Bounds()
+	if b.Dx() != 64 || (b.Dy() != 32 && b.Dy() != 64) {
+		return &ValidationError{Reason: fmt.Sprintf("Skins must be 64x32 or 64x64 pixels, but this image is %dx%d", b.Dx(), b.Dy()), img: img}
+	}
+
+	base, overlay := skinBaseRegions, skinOverlayRegions
+	if b.Dy() == 64 {
+		base = slices.Concat(base, skinModernBaseRegions)
+		overlay = slices.Concat(overlay, skinModernOverlayRegions)
+	}
+
+	// the first layer is drawn without blending, so transparency shows up as holes or black
+	failed := failingRegions(img, base, func(a uint8) bool { return a == 255 })
+	if len(failed) != 0 {
+		return &ValidationError{Reason: "The first layer of a skin must be fully opaque, but transparent pixels were found in the highlighted regions", Regions: failed, img: img}
+	}
+
+	// legacy clients cut off the second layer at half transparency instead of blending it
+	failed = failingRegions(img, overlay, func(a uint8) bool { return a == 0 || a == 255 })
+	if len(failed) != 0 {
+		return &ValidationError{Reason: "The second layer of a skin can only use fully transparent or fully opaque pixels, but partially transparent pixels were found in the highlighted regions", Regions: failed, img: img}
+	}
+
+	return nil
+}
+
+func validateCape(img image.Image) error {
+	b := img.Bounds()
+
+	for scale := 1; scale <= 4; scale++ {
+		if b.Dx() == 64*scale && b.Dy() == 32*scale {
+			return nil
+		}
+		if b.Dx() == 22*scale && b.Dy() == 17*scale {
+			return nil
+		}
+	}
+
+	return &ValidationError{Reason: fmt.Sprintf("Capes must be 64x32 or 22x17 pixels, or one of those sizes scaled up 2 to 4 times, but this image is %dx%d", b.Dx(), b.Dy()), img: img}
+}
+
+// failingRegions returns the regions containing a pixel whose alpha isn't ok
+func failingRegions(img image.Image, regions []Region, ok func(a uint8) bool) []Region {
+	b := img.Bounds()
+
+	var failed []Region
+	for _, r := range regions {
+	faces:
+		for _, f := range r.Faces {
+			for y := f.Min.Y; y < f.Max.Y; y++ {
+				for x := f.Min.X; x < f.Max.X; x++ {
+					if !ok(color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA).A) {
+						failed = append(failed, r)
+						break faces
+					}
+				}
+			}
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,182 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cosmetic
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// opaqueSkin returns a skin of the given height with an opaque first layer
+// and an empty second layer
+func opaqueSkin(h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, h))
+
+	regions := skinBaseRegions
+	if h == 64 {
+		regions = slices.Concat(regions, skinModernBaseRegions)
+	}
+
+	for _, r := range regions {
+		for _, f := range r.Faces {
+			draw.Draw(img, f, &image.Uniform{color.NRGBA{R: 200, A: 255}}, image.Point{}, draw.Src)
+		}
+	}
+
+	return img
+}
+
+func regionNames(err error) []string {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return nil
+	}
+
+	var names []string
+	for _, r := range verr.Regions {
+		names = append(names, r.Name)
+	}
+
+	return names
+}
+
+func TestValidateSkin(t *testing.T) {
+	tests := []struct {
+		name    string
+		height  int
+		pixels  map[image.Point]uint8 // alpha to set
+		valid   bool
+		regions []string
+	}{
+		{"legacy", 32, nil, true, nil},
+		{"modern", 64, nil, true, nil},
+		{"opaque hat", 32, map[image.Point]uint8{{40, 0}: 255}, true, nil},
+		{"transparent head", 32, map[image.Point]uint8{{8, 8}: 0}, false, []string{"head"}},
+		{"translucent body and leg", 32, map[image.Point]uint8{{20, 20}: 200, {4, 20}: 0}, false, []string{"body", "right leg"}},
+		{"transparent left arm", 64, map[image.Point]uint8{{36, 52}: 0}, false, []string{"left arm"}},
+		{"half transparent hat", 32, map[image.Point]uint8{{40, 8}: 128}, false, []string{"hat"}},
+		{"half transparent jacket", 64, map[image.Point]uint8{{20, 36}: 128}, false, []string{"jacket"}},
+		{"half transparent left sleeve", 64, map[image.Point]uint8{{52, 52}: 1}, false, []string{"left sleeve"}},
+		// first layer problems are reported before second layer ones
+		{"both layers", 64, map[image.Point]uint8{{8, 8}: 0, {40, 8}: 128}, false, []string{"head"}},
+	}
+
+	for _, tt := range tests {
+		img := opaqueSkin(tt.height)
+		for p, a := range tt.pixels {
+			img.SetNRGBA(p.X, p.Y, color.NRGBA{B: 200, A: a})
+		}
+
+		err := Validate(Skin, img)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: got %v, want valid %t", tt.name, err, tt.valid)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+
+		names := regionNames(err)
+		if !slices.Equal(names, tt.regions) {
+			t.Errorf("%s: failed regions %q, want %q", tt.name, names, tt.regions)
+		}
+		for _, name := range tt.regions {
+			if !strings.Contains(err.Error(), name) {
+				t.Errorf("%s: %q doesn't name region %s", tt.name, err, name)
+			}
+		}
+	}
+}
+
+func TestValidateSizes(t *testing.T) {
+	tests := []struct {
+		kind  Kind
+		w, h  int
+		valid bool
+	}{
+		{Skin, 64, 32, true},
+		{Skin, 64, 64, true},
+		{Skin, 32, 32, false},
+		{Skin, 64, 48, false},
+		{Skin, 128, 64, false},
+		{Cape, 64, 32, true},
+		{Cape, 22, 17, true},
+		{Cape, 128, 64, true},
+		{Cape, 44, 34, true},
+		{Cape, 192, 96, true},
+		{Cape, 88, 68, true},
+		{Cape, 256, 128, true},
+		{Cape, 320, 160, false},
+		{Cape, 110, 85, false},
+		{Cape, 64, 64, false},
+		{Cape, 23, 17, false},
+	}
+
+	for _, tt := range tests {
+		img := image.NewNRGBA(image.Rect(0, 0, tt.w, tt.h))
+		if tt.kind == Skin && tt.w == 64 && (tt.h == 32 || tt.h == 64) {
+			img = opaqueSkin(tt.h)
+		}
+
+		err := Validate(tt.kind, img)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s %dx%d: got %v, want valid %t", tt.kind, tt.w, tt.h, err, tt.valid)
+		}
+		if err != nil && len(regionNames(err)) != 0 {
+			t.Errorf("%s %dx%d: size errors name regions %q", tt.kind, tt.w, tt.h, regionNames(err))
+		}
+	}
+}
+
+func TestValidationPreview(t *testing.T) {
+	img := opaqueSkin(32)
+	img.SetNRGBA(8, 8, color.NRGBA{})
+
+	var verr *ValidationError
+	if !errors.As(Validate(Skin, img), &verr) {
+		t.Fatal("a transparent head validated")
+	}
+
+	b, err := verr.Preview()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	preview, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Bounds() != image.Rect(0, 0, 256, 128) {
+		t.Errorf("preview bounds %v, want 256x128", preview.Bounds())
+	}
+
+	// the head is highlighted, the body isn't
+	head := color.NRGBAModel.Convert(preview.At(8*4, 8*4)).(color.NRGBA)
+	body := color.NRGBAModel.Convert(preview.At(20*4, 20*4)).(color.NRGBA)
+	if head.R != 255 || body != (color.NRGBA{R: 200, A: 255}) {
+		t.Errorf("preview has head %v and body %v, want the head highlighted", head, body)
+	}
+}
@@ -20,31 +20,148 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// accountTables lists the tables holding per-user data by account id, removed
+// along with the account
+var accountTables = []string{"sessions", "tickets", "players", "versions", "roles", "bans", "tokens", "recovery_codes", "app_passwords", "entitlements", "cape_grants", "cape_selections", "cosmetics", "deletions", "invites", "pending_accounts"}
+
+// accountID is a subquery for the id of the account whose username is the
+// parameter, how per-user tables are reached by name
+const accountID = "(SELECT id FROM accounts WHERE username = ?)"
+
+var bcryptCost = bcrypt.DefaultCost
+
+// SetBcryptCost sets the cost new password hashes are made with, existing
+// ones below it are upgraded the next time the password is validated
+func SetBcryptCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	bcryptCost = cost
+	return nil
+}
+
 func InsertAccount(ctx context.Context, username string, password string) error {
-	digest, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return RegisterAccount(ctx, username, password, "", false)
+}
+
+// RegisterAccount creates an account, taking a use of the invite if it isn't
+// empty and leaving the account awaiting approval if pending is set. Either
+// all of it happens or none of it does.
+func RegisterAccount(ctx context.Context, username string, password string, invite string, pending bool) error {
+	digest, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	if err != nil {
 		return err
 	}
 
-	_, err = conn.ExecContext(ctx, "INSERT INTO accounts (username, password) VALUES (?, ?)", username, digest)
+	_, err = GetCanonicalUsername(ctx, username)
+	if err == nil {
+		return ErrNameTaken
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	reserved, err := IsNameReserved(ctx, username, "")
 	if err != nil {
 		return err
 	}
+	if reserved {
+		return ErrNameReserved
+	}
 
-	return nil
+	id, err := newAccountID()
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if invite != "" {
+		result, err := tx.ExecContext(ctx, "UPDATE invites SET uses = uses + 1 WHERE code = ? AND uses < max_uses AND (expires IS NULL OR expires > UTC_TIMESTAMP())", invite)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrInviteInvalid
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO accounts (id, username, password) VALUES (?, ?, ?)", id, username, digest)
+	if err != nil {
+		// registered by someone else since the check above
+		if isDuplicate(err) {
+			return ErrNameTaken
+		}
+
+		return err
+	}
+
+	if pending {
+		_, err = tx.ExecContext(ctx, "INSERT INTO pending_accounts (account) VALUES (?)", id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func DeleteAccount(ctx context.Context, username string) error {
-	_, err := conn.ExecContext(ctx, "DELETE FROM accounts WHERE username = ?", username)
+	id, err := GetAccountID(ctx, username)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+
 		return err
 	}
 
-	return nil
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, table := range accountTables {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE account = ?", id)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM name_history WHERE account = ?", id)
+	if err != nil {
+		return err
+	}
+
+	// last, so the name isn't free until nothing else of the account is left
+	_, err = tx.ExecContext(ctx, "DELETE FROM accounts WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func ValidatePassword(ctx context.Context, username string, password string) error {
@@ -59,11 +176,20 @@ func ValidatePassword(ctx context.Context, username string, password string) err
 		return err
 	}
 
+	// this is the only time the plaintext is around to rehash with
+	cost, err := bcrypt.Cost(stored)
+	if err == nil && cost < bcryptCost {
+		err = UpdatePassword(ctx, username, password)
+		if err != nil {
+			log.Printf("failed to upgrade password hash for %s: %s", username, err)
+		}
+	}
+
 	return nil
 }
 
 func UpdatePassword(ctx context.Context, username string, password string) error {
-	digest, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	digest, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	if err != nil {
 		return err
 	}
@@ -76,12 +202,57 @@ func UpdatePassword(ctx context.Context, username string, password string) error
 	return nil
 }
 
+// Usernames are case-insensitive. An account can be found by any casing of its
+// name but keeps the casing it was registered with, which is the canonical
+// username stored in every table. Anything taking a username from a request
+// has to resolve it with GetCanonicalUsername, or compare it to a canonical
+// one with SameUsername, instead of relying on the database collation.
+
+// UsernameKey returns the form usernames are compared in, which the database
+// keeps indexed in the username_key columns. Usernames are ASCII so
+// lowercasing is enough.
+func UsernameKey(username string) string {
+	return strings.ToLower(username)
+}
+
+// SameUsername reports whether two usernames refer to the same account
+func SameUsername(a string, b string) bool {
+	return UsernameKey(a) == UsernameKey(b)
+}
+
+// GetCanonicalUsername returns the canonical casing of the username, or
+// sql.ErrNoRows if no account has it
 func GetCanonicalUsername(ctx context.Context, username string) (string, error) {
 	var canonical string
-	err := conn.QueryRowContext(ctx, "SELECT username FROM accounts WHERE username = ?", username).Scan(&canonical)
+	err := conn.QueryRowContext(ctx, "SELECT username FROM accounts WHERE username_key = ?", UsernameKey(username)).Scan(&canonical)
 	if err != nil {
 		return "", err
 	}
 
 	return canonical, nil
 }
+
+// SearchAccounts returns up to limit usernames starting with query
+func SearchAccounts(ctx context.Context, query string, limit int) ([]string, error) {
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(UsernameKey(query)) + "%"
+
+	rows, err := conn.QueryContext(ctx, "SELECT username FROM accounts WHERE username_key LIKE ? ORDER BY username LIMIT ?", pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var usernames []string
+	for rows.Next() {
+		var username string
+		err = rows.Scan(&username)
+		if err != nil {
+			return nil, err
+		}
+
+		usernames = append(usernames, username)
+	}
+
+	return usernames, nil
+}
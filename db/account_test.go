@@ -0,0 +1,171 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestCanonicalUsername(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{username, nil},
+		{strings.ToLower(username), nil},
+		{strings.ToUpper(username), nil},
+		{"t" + strings.ToUpper(username[1:]), nil},
+		{username + "x", sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		canonical, err := db.GetCanonicalUsername(ctx, tt.name)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+
+		if err == nil && canonical != username {
+			t.Errorf("%s: got %s, want %s", tt.name, canonical, username)
+		}
+	}
+}
+
+func TestInsertAccountCase(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	for _, name := range []string{username, strings.ToLower(username), strings.ToUpper(username)} {
+		err := db.InsertAccount(ctx, name, "correct horse")
+		if !errors.Is(err, db.ErrNameTaken) {
+			t.Errorf("%s: got %v, want %v", name, err, db.ErrNameTaken)
+		}
+	}
+}
+
+func TestUsernameKey(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	// the schema rejects a case variant even if the checks are skipped
+	_, err := dbtest.Conn(t).Exec("INSERT INTO accounts (id, username, password) VALUES (?, ?, '')", strings.Repeat("0", 31)+"1", strings.ToUpper(username))
+	if err == nil {
+		dbtest.Exec(t, "DELETE FROM accounts WHERE username = ?", strings.ToUpper(username))
+		t.Fatal("inserted an account differing only by case")
+	}
+
+	found, err := db.SearchAccounts(ctx, strings.ToUpper(username[:8]), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(found, username) {
+		t.Errorf("searching for %s didn't find %s", strings.ToUpper(username[:8]), username)
+	}
+
+	// renaming onto another account's name in any case is refused
+	other := dbtest.Account(t, "correct horse")
+
+	err = db.RenameAccount(ctx, other, strings.ToLower(username))
+	if !errors.Is(err, db.ErrNameTaken) {
+		t.Errorf("renaming onto %s: got %v, want %v", strings.ToLower(username), err, db.ErrNameTaken)
+	}
+
+	// and old names are reserved in any case
+	err = db.RenameAccount(ctx, username, dbtest.Username(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reserved, err := db.IsNameReserved(ctx, strings.ToUpper(username), "")
+	if err != nil || !reserved {
+		t.Errorf("%s reserved %t, %v, want true", strings.ToUpper(username), reserved, err)
+	}
+}
+
+func TestRegisterAccount(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	inviter := dbtest.Account(t, "correct horse")
+	code := hex.EncodeToString(dbtest.Token(t))
+
+	err := db.InsertInvite(ctx, inviter, code, 1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a name that's taken doesn't use up the invite
+	err = db.RegisterAccount(ctx, inviter, "correct horse", code, false)
+	if !errors.Is(err, db.ErrNameTaken) {
+		t.Fatalf("got %v, want %v", err, db.ErrNameTaken)
+	}
+
+	invited := dbtest.Username(t)
+	err = db.RegisterAccount(ctx, invited, "correct horse", code, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := db.IsAccountPending(ctx, invited)
+	if err != nil || !pending {
+		t.Errorf("invited account is pending %t, %v, want true", pending, err)
+	}
+
+	// the invite's only use is gone, and nothing is left of the attempt
+	late := dbtest.Username(t)
+	err = db.RegisterAccount(ctx, late, "correct horse", code, true)
+	if !errors.Is(err, db.ErrInviteInvalid) {
+		t.Errorf("got %v, want %v", err, db.ErrInviteInvalid)
+	}
+
+	_, err = db.GetCanonicalUsername(ctx, late)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("account was created without an invite: %v", err)
+	}
+
+	pending, err = db.IsAccountPending(ctx, late)
+	if err != nil || pending {
+		t.Errorf("account that wasn't created is pending %t, %v", pending, err)
+	}
+
+	// accounts made without approval aren't pending
+	pending, err = db.IsAccountPending(ctx, inviter)
+	if err != nil || pending {
+		t.Errorf("regular account is pending %t, %v", pending, err)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AppPassword is a generated password that only works for the launcher.
+// Used is zero and IP empty until it's first used.
+type AppPassword struct {
+	ID      int64
+	Name    string
+	Created time.Time
+	Used    time.Time
+	IP      string
+}
+
+func InsertAppPassword(ctx context.Context, username string, name string, password string) error {
+	digest, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "INSERT INTO app_passwords (account, name, password) VALUES ("+accountID+", ?, ?)", username, name, digest)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetAppPasswords(ctx context.Context, username string) ([]AppPassword, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT id, name, created, used, ip FROM app_passwords WHERE account = "+accountID+" ORDER BY created", username)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var passwords []AppPassword
+	for rows.Next() {
+		var password AppPassword
+		var used sql.NullTime
+		var ip sql.NullString
+		err = rows.Scan(&password.ID, &password.Name, &password.Created, &used, &ip)
+		if err != nil {
+			return nil, err
+		}
+
+		password.Used = used.Time
+		password.IP = ip.String
+
+		passwords = append(passwords, password)
+	}
+
+	return passwords, nil
+}
+
+func DeleteAppPassword(ctx context.Context, username string, id int64) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM app_passwords WHERE account = "+accountID+" AND id = ?", username, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidateAppPassword checks password against each of the user's app
+// passwords and returns the id of the one that matched, or
+// bcrypt.ErrMismatchedHashAndPassword if none did
+func ValidateAppPassword(ctx context.Context, username string, password string) (int64, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT id, password FROM app_passwords WHERE account = "+accountID, username)
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var stored []byte
+		err = rows.Scan(&id, &stored)
+		if err != nil {
+			return 0, err
+		}
+
+		if bcrypt.CompareHashAndPassword(stored, []byte(password)) == nil {
+			return id, nil
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, bcrypt.ErrMismatchedHashAndPassword
+}
+
+// TouchAppPassword records that the app password was just used from ip
+func TouchAppPassword(ctx context.Context, id int64, ip string) error {
+	_, err := conn.ExecContext(ctx, "UPDATE app_passwords SET used = UTC_TIMESTAMP(), ip = ? WHERE id = ?", ip, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAppPasswords(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+	other := dbtest.Account(t, "correct horse")
+
+	for _, name := range []string{"laptop", "desktop"} {
+		err := db.InsertAppPassword(ctx, username, name, name+" password")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	passwords, err := db.GetAppPasswords(ctx, username)
+	if err != nil || len(passwords) != 2 {
+		t.Fatalf("got %d app passwords, %v, want 2", len(passwords), err)
+	}
+
+	desktop := findAppPassword(passwords, "desktop")
+	if !desktop.Used.IsZero() {
+		t.Fatalf("desktop app password is %+v, want an unused one", desktop)
+	}
+
+	id, err := db.ValidateAppPassword(ctx, username, "desktop password")
+	if err != nil || id != desktop.ID {
+		t.Errorf("desktop password matched %d, %v, want %d", id, err, desktop.ID)
+	}
+
+	// neither the account password nor another user's app password works
+	for _, c := range []struct{ username, password string }{
+		{username, "correct horse"},
+		{other, "desktop password"},
+	} {
+		_, err = db.ValidateAppPassword(ctx, c.username, c.password)
+		if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			t.Errorf("%q for %s: got %v, want a mismatch", c.password, c.username, err)
+		}
+	}
+
+	err = db.TouchAppPassword(ctx, desktop.ID, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// revoking needs the right owner
+	err = db.DeleteAppPassword(ctx, other, desktop.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passwords, err = db.GetAppPasswords(ctx, username)
+	if err != nil || len(passwords) != 2 {
+		t.Fatalf("got %d app passwords after another user revoked one, %v", len(passwords), err)
+	}
+	desktop = findAppPassword(passwords, "desktop")
+	if desktop.IP != "192.0.2.1" || desktop.Used.IsZero() {
+		t.Errorf("use wasn't recorded: %+v", desktop)
+	}
+
+	err = db.DeleteAppPassword(ctx, username, desktop.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.ValidateAppPassword(ctx, username, "desktop password")
+	if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		t.Errorf("revoked password: got %v, want a mismatch", err)
+	}
+}
+
+// findAppPassword returns the app password called name, since ones created in
+// the same second have no set order
+func findAppPassword(passwords []db.AppPassword, name string) db.AppPassword {
+	for _, password := range passwords {
+		if password.Name == name {
+			return password
+		}
+	}
+
+	return db.AppPassword{}
+}
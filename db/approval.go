@@ -0,0 +1,73 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// PendingAccount is a registration waiting for an admin's approval
+type PendingAccount struct {
+	Username string
+	Created  time.Time
+}
+
+func IsAccountPending(ctx context.Context, username string) (bool, error) {
+	var pending bool
+	err := conn.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM pending_accounts WHERE account = "+accountID+")", username).Scan(&pending)
+	if err != nil {
+		return false, err
+	}
+
+	return pending, nil
+}
+
+// GetPendingAccounts returns the registrations awaiting approval, oldest
+// first
+func GetPendingAccounts(ctx context.Context) ([]PendingAccount, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT a.username, p.created FROM pending_accounts p JOIN accounts a ON a.id = p.account ORDER BY p.created")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var accounts []PendingAccount
+	for rows.Next() {
+		var account PendingAccount
+		err := rows.Scan(&account.Username, &account.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+func ApproveAccount(ctx context.Context, username string) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM pending_accounts WHERE account = "+accountID, username)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
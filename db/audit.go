@@ -0,0 +1,73 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// AuditEntry is a record of an action performed by an admin
+type AuditEntry struct {
+	Time   time.Time
+	Admin  string
+	Action string
+	Target string
+	Detail string
+}
+
+func InsertAuditEntry(ctx context.Context, admin string, action string, target string, detail string) error {
+	_, err := conn.ExecContext(ctx, "INSERT INTO audit_log (admin, action, target, detail) VALUES (?, ?, ?, ?)", admin, action, target, detail)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetAuditLog returns the most recent audit entries, limited to those
+// targeting the given user if target isn't empty
+func GetAuditLog(ctx context.Context, target string, limit int) ([]AuditEntry, error) {
+	query := "SELECT time, admin, action, target, detail FROM audit_log ORDER BY time DESC, id DESC LIMIT ?"
+	args := []any{limit}
+	if target != "" {
+		query = "SELECT time, admin, action, target, detail FROM audit_log WHERE target = ? ORDER BY time DESC, id DESC LIMIT ?"
+		args = []any{target, limit}
+	}
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		err = rows.Scan(&entry.Time, &entry.Admin, &entry.Action, &entry.Target, &entry.Detail)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,103 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Ban stops a user from logging in or joining servers until it expires or
+// is lifted. Bans without an expiry time are permanent.
+type Ban struct {
+	ID       int
+	Username string
+	Reason   string
+	Issuer   string
+	Issued   time.Time
+	Expires  time.Time
+	Lifted   bool
+}
+
+// Permanent reports whether the ban never expires
+func (b Ban) Permanent() bool {
+	return b.Expires.IsZero()
+}
+
+func scanBan(row interface{ Scan(...any) error }) (Ban, error) {
+	var ban Ban
+	var expires sql.NullTime
+	err := row.Scan(&ban.ID, &ban.Username, &ban.Reason, &ban.Issuer, &ban.Issued, &expires, &ban.Lifted)
+	if err != nil {
+		return Ban{}, err
+	}
+
+	ban.Expires = expires.Time
+
+	return ban, nil
+}
+
+// InsertBan bans the user until expires, or permanently if it's zero
+func InsertBan(ctx context.Context, username string, reason string, issuer string, expires time.Time) error {
+	_, err := conn.ExecContext(ctx, "INSERT INTO bans (account, reason, issuer, expires) VALUES ("+accountID+", ?, ?, ?)", username, reason, issuer, sql.NullTime{Time: expires, Valid: !expires.IsZero()})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetActiveBan returns the user's longest running ban that's in effect, or
+// sql.ErrNoRows if they aren't banned
+func GetActiveBan(ctx context.Context, username string) (Ban, error) {
+	return scanBan(conn.QueryRowContext(ctx, "SELECT b.id, a.username, b.reason, b.issuer, b.issued, b.expires, b.lifted FROM bans b JOIN accounts a ON a.id = b.account WHERE a.username = ? AND b.lifted = 0 AND (b.expires IS NULL OR b.expires > UTC_TIMESTAMP()) ORDER BY b.expires IS NULL DESC, b.expires DESC LIMIT 1", username))
+}
+
+// GetBans returns every ban the user has received, newest first
+func GetBans(ctx context.Context, username string) ([]Ban, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT b.id, a.username, b.reason, b.issuer, b.issued, b.expires, b.lifted FROM bans b JOIN accounts a ON a.id = b.account WHERE a.username = ? ORDER BY b.issued DESC", username)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var bans []Ban
+	for rows.Next() {
+		ban, err := scanBan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		bans = append(bans, ban)
+	}
+
+	return bans, nil
+}
+
+// LiftBans ends every ban the user is under
+func LiftBans(ctx context.Context, username string) error {
+	_, err := conn.ExecContext(ctx, "UPDATE bans SET lifted = 1 WHERE account = "+accountID+" AND lifted = 0", username)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
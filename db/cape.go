@@ -0,0 +1,165 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Cape is a cape design that admins can grant to users
+type Cape struct {
+	ID   int
+	Name string
+	Hash string
+}
+
+func scanCapes(rows *sql.Rows) ([]Cape, error) {
+	defer rows.Close()
+
+	var capes []Cape
+	for rows.Next() {
+		var cape Cape
+		err := rows.Scan(&cape.ID, &cape.Name, &cape.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		capes = append(capes, cape)
+	}
+
+	return capes, nil
+}
+
+func InsertCape(ctx context.Context, name string, hash string) error {
+	_, err := conn.ExecContext(ctx, "INSERT INTO capes (name, hash) VALUES (?, ?)", name, hash)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func DeleteCape(ctx context.Context, id int) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM capes WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "DELETE FROM cape_grants WHERE cape = ?", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "DELETE FROM cape_selections WHERE cape = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetCapes(ctx context.Context) ([]Cape, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT id, name, hash FROM capes ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanCapes(rows)
+}
+
+// GetUserCapes returns the capes granted to the user
+func GetUserCapes(ctx context.Context, username string) ([]Cape, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT c.id, c.name, c.hash FROM capes c JOIN cape_grants g ON g.cape = c.id WHERE g.account = "+accountID+" ORDER BY c.name", username)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanCapes(rows)
+}
+
+func GrantCape(ctx context.Context, username string, id int) error {
+	_, err := conn.ExecContext(ctx, "REPLACE INTO cape_grants (account, cape) VALUES ("+accountID+", ?)", username, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func RevokeCape(ctx context.Context, username string, id int) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM cape_grants WHERE account = "+accountID+" AND cape = ?", username, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetSelectedCape returns the id of the granted cape the user is wearing
+func GetSelectedCape(ctx context.Context, username string) (int, error) {
+	var id int
+	err := conn.QueryRowContext(ctx, "SELECT cape FROM cape_selections WHERE account = "+accountID, username).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetCapeWearers returns the users wearing the given cape
+func GetCapeWearers(ctx context.Context, id int) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT a.username FROM cape_selections s JOIN accounts a ON a.id = s.account WHERE s.cape = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var usernames []string
+	for rows.Next() {
+		var username string
+		err = rows.Scan(&username)
+		if err != nil {
+			return nil, err
+		}
+
+		usernames = append(usernames, username)
+	}
+
+	return usernames, nil
+}
+
+func SetSelectedCape(ctx context.Context, username string, id int) error {
+	_, err := conn.ExecContext(ctx, "REPLACE INTO cape_selections (account, cape) VALUES ("+accountID+", ?)", username, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func ClearSelectedCape(ctx context.Context, username string) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM cape_selections WHERE account = "+accountID, username)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	CosmeticApproved = "approved"
+	CosmeticPending  = "pending"
+	CosmeticRejected = "rejected"
+)
+
+type CosmeticEntry struct {
+	Username string
+	Kind     string
+	Hash     string
+	Uploaded time.Time
+	Status   string
+	Reason   string
+}
+
+func scanCosmetics(rows *sql.Rows) ([]CosmeticEntry, error) {
+	defer rows.Close()
+
+	var entries []CosmeticEntry
+	for rows.Next() {
+		var entry CosmeticEntry
+		err := rows.Scan(&entry.Username, &entry.Kind, &entry.Hash, &entry.Uploaded, &entry.Status, &entry.Reason)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// InsertCosmetic records an upload in the user's history, moving it to the
+// top if the same image was uploaded before
+func InsertCosmetic(ctx context.Context, username string, kind string, hash string, status string) error {
+	_, err := conn.ExecContext(ctx, "INSERT INTO cosmetics (account, kind, hash, status) VALUES ("+accountID+", ?, ?, ?) ON DUPLICATE KEY UPDATE uploaded = UTC_TIMESTAMP(), status = VALUES(status), reason = ''", username, kind, hash, status)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetCosmeticHistory(ctx context.Context, username string, kind string) ([]CosmeticEntry, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT a.username, c.kind, c.hash, c.uploaded, c.status, c.reason FROM cosmetics c JOIN accounts a ON a.id = c.account WHERE a.username = ? AND c.kind = ? ORDER BY c.uploaded DESC", username, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanCosmetics(rows)
+}
+
+// GetPendingCosmetics returns every upload awaiting moderation, oldest first
+func GetPendingCosmetics(ctx context.Context) ([]CosmeticEntry, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT a.username, c.kind, c.hash, c.uploaded, c.status, c.reason FROM cosmetics c JOIN accounts a ON a.id = c.account WHERE c.status = ? ORDER BY c.uploaded", CosmeticPending)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanCosmetics(rows)
+}
+
+func GetCosmeticStatus(ctx context.Context, username string, kind string, hash string) (string, error) {
+	var status string
+	err := conn.QueryRowContext(ctx, "SELECT status FROM cosmetics WHERE account = "+accountID+" AND kind = ? AND hash = ?", username, kind, hash).Scan(&status)
+	if err != nil {
+		return "", err
+	}
+
+	return status, nil
+}
+
+func SetCosmeticStatus(ctx context.Context, username string, kind string, hash string, status string, reason string) error {
+	_, err := conn.ExecContext(ctx, "UPDATE cosmetics SET status = ?, reason = ? WHERE account = "+accountID+" AND kind = ? AND hash = ?", status, reason, username, kind, hash)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// IsCosmeticApproved reports whether the image with the given hash may be
+// shown publicly, either as an approved upload or as an admin cape design
+func IsCosmeticApproved(ctx context.Context, hash string) (bool, error) {
+	var approved bool
+	err := conn.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM cosmetics WHERE hash = ? AND status = ?) OR EXISTS(SELECT 1 FROM capes WHERE hash = ?)", hash, CosmeticApproved, hash).Scan(&approved)
+	if err != nil {
+		return false, err
+	}
+
+	return approved, nil
+}
+
+// IsCosmeticReferenced reports whether anything other than the account named
+// except uses the image with the given hash, so it can be removed from
+// storage when nothing does. except can be empty to count every account.
+func IsCosmeticReferenced(ctx context.Context, hash string, except string) (bool, error) {
+	var referenced bool
+	err := conn.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM cosmetics WHERE hash = ? AND account NOT IN (SELECT id FROM accounts WHERE username = ?)) OR EXISTS(SELECT 1 FROM capes WHERE hash = ?)", hash, except, hash).Scan(&referenced)
+	if err != nil {
+		return false, err
+	}
+
+	return referenced, nil
+}
+
+func HasCosmetic(ctx context.Context, username string, kind string, hash string) (bool, error) {
+	var count int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM cosmetics WHERE account = "+accountID+" AND kind = ? AND hash = ?", username, kind, hash).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+// IsLatestCosmetic reports whether nothing was uploaded or restored by the
+// user after the given entry in their history
+func IsLatestCosmetic(ctx context.Context, username string, kind string, hash string) (bool, error) {
+	var newer bool
+	err := conn.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM cosmetics c JOIN cosmetics e ON e.account = c.account AND e.kind = c.kind WHERE c.account = "+accountID+" AND c.kind = ? AND c.hash = ? AND e.uploaded > c.uploaded)", username, kind, hash).Scan(&newer)
+	if err != nil {
+		return false, err
+	}
+
+	return !newer, nil
+}
@@ -0,0 +1,166 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package dbtest
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+var (
+	initOnce sync.Once
+	initErr  error
+	dsn      string
+)
+
+// Init connects the db package to the database named by the TEST_DB_*
+// variables and migrates it, or skips the test if TEST_DB_ADDR isn't set.
+// Every test shares the database, so each has to make its own accounts.
+func Init(t testing.TB) {
+	t.Helper()
+
+	if os.Getenv("TEST_DB_ADDR") == "" {
+		t.Skip("TEST_DB_ADDR isn't set")
+	}
+
+	initOnce.Do(func() {
+		user := os.Getenv("TEST_DB_USER")
+		if user == "" {
+			user = "root"
+		}
+
+		name := os.Getenv("TEST_DB_NAME")
+		if name == "" {
+			name = "betablock_test"
+		}
+
+		initErr = db.Init(user, os.Getenv("TEST_DB_PASS"), "tcp", os.Getenv("TEST_DB_ADDR"), name)
+		if initErr != nil {
+			return
+		}
+
+		initErr = db.Migrate(context.Background())
+		if initErr != nil {
+			return
+		}
+
+		dsn = fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", user, os.Getenv("TEST_DB_PASS"), os.Getenv("TEST_DB_ADDR"), name)
+
+		initErr = seed()
+	})
+
+	if initErr != nil {
+		t.Fatalf("failed to set up the test database: %s", initErr)
+	}
+}
+
+// seed adds the rows the website expects an operator to have loaded. pages
+// show the realtime version, which needs a version released on or before
+// the start of the realtime year.
+func seed() error {
+	handle, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return err
+	}
+
+	defer handle.Close()
+
+	_, err = handle.Exec("INSERT IGNORE INTO timeline (id, released) VALUES ('a1.2.0', '2010-10-31')")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Conn returns a separate handle on the test database, for setting up rows
+// the db package has no way to make, such as ones from the past, or checking
+// what the schema allows
+func Conn(t testing.TB) *sql.DB {
+	t.Helper()
+
+	handle, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { handle.Close() })
+
+	return handle
+}
+
+// Exec runs a statement against the test database through Conn
+func Exec(t testing.TB, query string, args ...any) {
+	t.Helper()
+
+	_, err := Conn(t).Exec(query, args...)
+	if err != nil {
+		t.Fatalf("failed to run %q: %s", query, err)
+	}
+}
+
+// Username returns a username no other test uses
+func Username(t testing.TB) string {
+	t.Helper()
+
+	b := make([]byte, 5)
+	_, err := rand.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "Test" + hex.EncodeToString(b)
+}
+
+// Account creates an account with a unique username and returns its name
+func Account(t testing.TB, password string) string {
+	t.Helper()
+
+	username := Username(t)
+
+	err := db.InsertAccount(context.Background(), username, password)
+	if err != nil {
+		t.Fatalf("failed to create account: %s", err)
+	}
+
+	return username
+}
+
+// Token returns random bytes for a session, ticket or token no other test uses
+func Token(t testing.TB) []byte {
+	t.Helper()
+
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
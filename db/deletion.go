@@ -0,0 +1,82 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// DeletionGracePeriod is how long a deletion can be cancelled for
+const DeletionGracePeriod = 14 * 24 * time.Hour
+
+// ScheduleDeletion marks the account to be deleted once the grace period is
+// over
+func ScheduleDeletion(ctx context.Context, username string) error {
+	_, err := conn.ExecContext(ctx, "REPLACE INTO deletions (account, due) VALUES ("+accountID+", ?)", username, time.Now().UTC().Add(DeletionGracePeriod))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func CancelDeletion(ctx context.Context, username string) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM deletions WHERE account = "+accountID, username)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetScheduledDeletion returns when the account will be deleted, or
+// sql.ErrNoRows if it won't
+func GetScheduledDeletion(ctx context.Context, username string) (time.Time, error) {
+	var due time.Time
+	err := conn.QueryRowContext(ctx, "SELECT due FROM deletions WHERE account = "+accountID, username).Scan(&due)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return due, nil
+}
+
+// GetDueDeletions returns the accounts whose grace period is over
+func GetDueDeletions(ctx context.Context) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT a.username FROM deletions d JOIN accounts a ON a.id = d.account WHERE d.due <= UTC_TIMESTAMP()")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var usernames []string
+	for rows.Next() {
+		var username string
+		err := rows.Scan(&username)
+		if err != nil {
+			return nil, err
+		}
+
+		usernames = append(usernames, username)
+	}
+
+	return usernames, nil
+}
@@ -0,0 +1,84 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrEmailTaken is returned when verifying an address that's already
+// verified on another account
+var ErrEmailTaken = errors.New("email address is verified on another account")
+
+// GetEmail returns the user's email address and whether it's been verified.
+// The address is empty if the user hasn't set one.
+func GetEmail(ctx context.Context, username string) (string, bool, error) {
+	var email sql.NullString
+	var verified bool
+	err := conn.QueryRowContext(ctx, "SELECT email, email_verified FROM accounts WHERE username = ?", username).Scan(&email, &verified)
+	if err != nil {
+		return "", false, err
+	}
+
+	return email.String, verified, nil
+}
+
+// SetEmail changes the user's email address, which will need to be verified
+// again. An empty address removes it.
+func SetEmail(ctx context.Context, username string, email string) error {
+	_, err := conn.ExecContext(ctx, "UPDATE accounts SET email = ?, email_verified = 0 WHERE username = ?", sql.NullString{String: email, Valid: email != ""}, username)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the user's address as verified if it's still email. It
+// returns ErrEmailTaken if another account verified the address first.
+func VerifyEmail(ctx context.Context, username string, email string) (bool, error) {
+	res, err := conn.ExecContext(ctx, "UPDATE accounts SET email_verified = 1 WHERE username = ? AND email = ?", username, email)
+	if err != nil {
+		if isDuplicate(err) {
+			return false, ErrEmailTaken
+		}
+
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n != 0, nil
+}
+
+// GetUsernameFromEmail returns the user the verified address belongs to
+func GetUsernameFromEmail(ctx context.Context, email string) (string, error) {
+	var username string
+	err := conn.QueryRowContext(ctx, "SELECT username FROM accounts WHERE verified_email = ?", email).Scan(&username)
+	if err != nil {
+		return "", err
+	}
+
+	return username, nil
+}
@@ -0,0 +1,77 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestVerifiedEmailUnique(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	first := dbtest.Account(t, "correct horse")
+	second := dbtest.Account(t, "correct horse")
+
+	email := first + "@example.com"
+
+	// anyone can enter an address, only one account can verify it
+	for _, username := range []string{first, second} {
+		err := db.SetEmail(ctx, username, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ok, err := db.VerifyEmail(ctx, first, email)
+	if err != nil || !ok {
+		t.Fatalf("verifying the first account returned %t, %v", ok, err)
+	}
+
+	_, err = db.VerifyEmail(ctx, second, email)
+	if !errors.Is(err, db.ErrEmailTaken) {
+		t.Errorf("verifying the second account returned %v, want ErrEmailTaken", err)
+	}
+
+	owner, err := db.GetUsernameFromEmail(ctx, email)
+	if err != nil || owner != first {
+		t.Errorf("address belongs to %q, %v, want %s", owner, err, first)
+	}
+
+	// it's free again once the first account lets go of it
+	err = db.SetEmail(ctx, first, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err = db.VerifyEmail(ctx, second, email)
+	if err != nil || !ok {
+		t.Errorf("verifying the released address returned %t, %v", ok, err)
+	}
+
+	owner, err = db.GetUsernameFromEmail(ctx, email)
+	if err != nil || owner != second {
+		t.Errorf("address belongs to %q, %v, want %s", owner, err, second)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import "context"
+
+const EntitlementCustomCape = "customcape"
+
+func HasEntitlement(ctx context.Context, username string, entitlement string) (bool, error) {
+	var count int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM entitlements WHERE account = "+accountID+" AND entitlement = ?", username, entitlement).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+func GrantEntitlement(ctx context.Context, username string, entitlement string) error {
+	_, err := conn.ExecContext(ctx, "REPLACE INTO entitlements (account, entitlement) VALUES ("+accountID+", ?)", username, entitlement)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func RevokeEntitlement(ctx context.Context, username string, entitlement string) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM entitlements WHERE account = "+accountID+" AND entitlement = ?", username, entitlement)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
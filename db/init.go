@@ -20,7 +20,10 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 var conn *sql.DB
@@ -34,3 +37,9 @@ func Init(username string, password string, protocol string, address string, dat
 	conn = handle
 	return nil
 }
+
+// isDuplicate reports whether err is a unique index violation
+func isDuplicate(err error) bool {
+	var merr *mysql.MySQLError
+	return errors.As(err, &merr) && merr.Number == 1062
+}
@@ -0,0 +1,122 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrInviteInvalid = errors.New("invite doesn't exist, is used up or has expired")
+
+// Invite is a code that lets people register while registration is invite
+// only
+type Invite struct {
+	Code     string
+	Username string // who created it
+	Created  time.Time
+	Expires  time.Time // zero if it never expires
+	Uses     int
+	MaxUses  int
+}
+
+// Usable reports whether the invite can still be used to register
+func (i Invite) Usable() bool {
+	return i.Uses < i.MaxUses && (i.Expires.IsZero() || time.Now().Before(i.Expires))
+}
+
+// InsertInvite creates an invite, a zero expiry means it never expires
+func InsertInvite(ctx context.Context, username string, code string, maxUses int, expires time.Time) error {
+	var exp sql.NullTime
+	if !expires.IsZero() {
+		exp = sql.NullTime{Time: expires.UTC(), Valid: true}
+	}
+
+	_, err := conn.ExecContext(ctx, "INSERT INTO invites (code, account, expires, max_uses) VALUES (?, "+accountID+", ?, ?)", code, username, exp, maxUses)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetInvites returns the invites the user created, newest first, or every
+// invite if username is empty
+func GetInvites(ctx context.Context, username string) ([]Invite, error) {
+	query := "SELECT i.code, a.username, i.created, i.expires, i.uses, i.max_uses FROM invites i JOIN accounts a ON a.id = i.account ORDER BY i.created DESC"
+	args := []any{}
+	if username != "" {
+		query = "SELECT i.code, a.username, i.created, i.expires, i.uses, i.max_uses FROM invites i JOIN accounts a ON a.id = i.account WHERE a.username = ? ORDER BY i.created DESC"
+		args = append(args, username)
+	}
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var invites []Invite
+	for rows.Next() {
+		var invite Invite
+		var expires sql.NullTime
+		err := rows.Scan(&invite.Code, &invite.Username, &invite.Created, &expires, &invite.Uses, &invite.MaxUses)
+		if err != nil {
+			return nil, err
+		}
+
+		invite.Expires = expires.Time
+
+		invites = append(invites, invite)
+	}
+
+	return invites, nil
+}
+
+// CountUsableInvites returns how many of the user's invites can still be used
+func CountUsableInvites(ctx context.Context, username string) (int, error) {
+	var count int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM invites WHERE account = "+accountID+" AND uses < max_uses AND (expires IS NULL OR expires > UTC_TIMESTAMP())", username).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// DeleteInvite removes an invite, only the user's own unless username is
+// empty
+func DeleteInvite(ctx context.Context, username string, code string) error {
+	query := "DELETE FROM invites WHERE code = ?"
+	args := []any{code}
+	if username != "" {
+		query = "DELETE FROM invites WHERE code = ? AND account = " + accountID
+		args = append(args, username)
+	}
+
+	_, err := conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestInviteLastUse(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	inviter := dbtest.Account(t, "correct horse")
+	code := hex.EncodeToString(dbtest.Token(t))
+
+	err := db.InsertInvite(ctx, inviter, code, 2, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// everyone races for the last use once the first is taken
+	err = db.RegisterAccount(ctx, dbtest.Username(t), "correct horse", code, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const racers = 8
+
+	usernames := make([]string, racers)
+	errs := make([]error, racers)
+	for i := range usernames {
+		usernames[i] = dbtest.Username(t)
+	}
+
+	var wg sync.WaitGroup
+	for i := range racers {
+		wg.Go(func() {
+			errs[i] = db.RegisterAccount(ctx, usernames[i], "correct horse", code, false)
+		})
+	}
+
+	wg.Wait()
+
+	var registered int
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			registered++
+		case errors.Is(err, db.ErrInviteInvalid):
+			// the account mustn't exist without the use
+			_, err = db.GetCanonicalUsername(ctx, usernames[i])
+			if err == nil {
+				t.Errorf("%s was registered without a use of the invite", usernames[i])
+			}
+		default:
+			t.Errorf("%s: %s", usernames[i], err)
+		}
+	}
+
+	if registered != 1 {
+		t.Errorf("%d accounts got the last use, want 1", registered)
+	}
+
+	invites, err := db.GetInvites(ctx, inviter)
+	if err != nil || len(invites) != 1 {
+		t.Fatalf("got %d invites, %v", len(invites), err)
+	}
+	if invites[0].Uses != 2 || invites[0].Usable() {
+		t.Errorf("invite was used %d times and usable %t, want used up", invites[0].Uses, invites[0].Usable())
+	}
+}
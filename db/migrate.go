@@ -0,0 +1,141 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationSetup holds steps a migration needs done in Go before its
+// statements run, keyed by the migration's file name
+var migrationSetup = map[string]func(ctx context.Context, c *sql.Conn) error{
+	"015_account_keys.sql": assignAccountIDs,
+}
+
+// Migrate brings the schema up to date, applying every migration that hasn't
+// been yet in order of their file names. Instances starting at the same time
+// wait for each other. Schema changes can't be rolled back in MySQL, so a
+// migration that fails partway has to be finished by hand.
+func Migrate(ctx context.Context) error {
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	var locked sql.NullBool
+	err = c.QueryRowContext(ctx, "SELECT GET_LOCK('betablock_migrate', 60)").Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if !locked.Bool {
+		return errors.New("timed out waiting for another instance to finish migrating")
+	}
+
+	defer c.ExecContext(context.Background(), "SELECT RELEASE_LOCK('betablock_migrate')")
+
+	_, err = c.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (name VARCHAR(64) NOT NULL PRIMARY KEY, applied DATETIME NOT NULL)")
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrationsFS, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		name = path.Base(name)
+
+		var applied bool
+		err = c.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE name = ?)", name).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		err = applyMigration(ctx, c, name)
+		if err != nil {
+			return fmt.Errorf("migration %s failed: %w", name, err)
+		}
+
+		log.Printf("applied migration %s", name)
+	}
+
+	return nil
+}
+
+// applyMigration runs a migration's statements, which are separated by
+// semicolons at the end of a line
+func applyMigration(ctx context.Context, c *sql.Conn, name string) error {
+	setup, ok := migrationSetup[name]
+	if ok {
+		err := setup(ctx, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	b, err := migrationsFS.ReadFile("migrations/" + name)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range strings.Split(string(b), ";\n") {
+		var lines []string
+		for _, line := range strings.Split(stmt, "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "--") {
+				continue
+			}
+
+			lines = append(lines, line)
+		}
+
+		stmt = strings.TrimSpace(strings.Join(lines, "\n"))
+		if stmt == "" {
+			continue
+		}
+
+		_, err = c.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = c.ExecContext(ctx, "INSERT INTO schema_migrations (name, applied) VALUES (?, UTC_TIMESTAMP())", name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
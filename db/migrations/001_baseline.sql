@@ -0,0 +1,44 @@
+-- The schema as it was before there were migrations. Existing databases
+-- already have these tables, so this only creates them on new ones.
+
+CREATE TABLE IF NOT EXISTS accounts (
+	username VARCHAR(16) NOT NULL PRIMARY KEY,
+	password VARBINARY(60) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	username VARCHAR(16) NOT NULL PRIMARY KEY,
+	session VARBINARY(16) NOT NULL,
+	issued DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP())
+);
+
+CREATE TABLE IF NOT EXISTS tickets (
+	username VARCHAR(16) NOT NULL PRIMARY KEY,
+	ticket VARBINARY(16) NOT NULL,
+	issued DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	INDEX (ticket)
+);
+
+CREATE TABLE IF NOT EXISTS players (
+	username VARCHAR(16) NOT NULL PRIMARY KEY,
+	server VARBINARY(64) NOT NULL,
+	issued DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP())
+);
+
+CREATE TABLE IF NOT EXISTS versions (
+	username VARCHAR(16) NOT NULL PRIMARY KEY,
+	version VARCHAR(32) NOT NULL,
+	changed DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP())
+);
+
+CREATE TABLE IF NOT EXISTS timeline (
+	id VARCHAR(32) NOT NULL PRIMARY KEY,
+	released DATE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS news (
+	id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	title VARCHAR(255) NOT NULL,
+	body TEXT NOT NULL,
+	posted DATETIME NOT NULL
+);
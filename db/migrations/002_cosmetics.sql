@@ -0,0 +1,10 @@
+-- Every skin and cape a user has uploaded, by the hash of the stored image
+
+CREATE TABLE cosmetics (
+	username VARCHAR(16) NOT NULL,
+	kind VARCHAR(8) NOT NULL,
+	hash CHAR(64) NOT NULL,
+	uploaded DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	PRIMARY KEY (username, kind, hash),
+	INDEX (hash)
+);
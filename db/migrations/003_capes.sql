@@ -0,0 +1,28 @@
+-- Cape designs managed by admins, who they're granted to, which one each
+-- user wears and entitlements such as uploading a custom cape
+
+CREATE TABLE capes (
+	id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(32) NOT NULL,
+	hash CHAR(64) NOT NULL,
+	INDEX (hash)
+);
+
+CREATE TABLE cape_grants (
+	username VARCHAR(16) NOT NULL,
+	cape INT NOT NULL,
+	PRIMARY KEY (username, cape),
+	INDEX (cape)
+);
+
+CREATE TABLE cape_selections (
+	username VARCHAR(16) NOT NULL PRIMARY KEY,
+	cape INT NOT NULL,
+	INDEX (cape)
+);
+
+CREATE TABLE entitlements (
+	username VARCHAR(16) NOT NULL,
+	entitlement VARCHAR(32) NOT NULL,
+	PRIMARY KEY (username, entitlement)
+);
@@ -0,0 +1,8 @@
+-- Uploads can wait for review. Ones from before there was moderation were
+-- already public, so they count as approved.
+
+ALTER TABLE cosmetics ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'approved';
+
+ALTER TABLE cosmetics ADD COLUMN reason VARCHAR(255) NOT NULL DEFAULT '';
+
+ALTER TABLE cosmetics ADD INDEX (status);
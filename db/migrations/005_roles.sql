@@ -0,0 +1,20 @@
+-- Staff roles, the log of what staff did with them and the accounts staff
+-- have disabled
+
+CREATE TABLE roles (
+	username VARCHAR(16) NOT NULL PRIMARY KEY,
+	role VARCHAR(16) NOT NULL
+);
+
+CREATE TABLE audit_log (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	time DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	admin VARCHAR(16) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	target VARCHAR(16) NOT NULL,
+	detail VARCHAR(512) NOT NULL DEFAULT '',
+	INDEX (target),
+	INDEX (time)
+);
+
+ALTER TABLE accounts ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,17 @@
+-- Bans, kept after they expire or are lifted as the user's history. They
+-- replace disabling accounts, so disabled accounts become permanently banned.
+
+CREATE TABLE bans (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	username VARCHAR(16) NOT NULL,
+	reason VARCHAR(128) NOT NULL,
+	issuer VARCHAR(16) NOT NULL,
+	issued DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	expires DATETIME NULL,
+	lifted BOOLEAN NOT NULL DEFAULT FALSE,
+	INDEX (username)
+);
+
+INSERT INTO bans (username, reason, issuer) SELECT username, 'Account disabled', '' FROM accounts WHERE disabled;
+
+ALTER TABLE accounts DROP COLUMN disabled;
@@ -0,0 +1,17 @@
+-- Users can have any number of sessions, each with its own id, type and the
+-- address it was last used from. Sessions from before there were types keep
+-- the day long expiry they were issued with.
+
+ALTER TABLE sessions DROP PRIMARY KEY;
+
+ALTER TABLE sessions ADD COLUMN id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY FIRST;
+
+ALTER TABLE sessions ADD COLUMN type VARCHAR(16) NOT NULL DEFAULT 'launcher';
+
+ALTER TABLE sessions ADD COLUMN ip VARCHAR(45) NOT NULL DEFAULT '';
+
+ALTER TABLE sessions ADD COLUMN used DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP());
+
+ALTER TABLE sessions ADD UNIQUE INDEX (session);
+
+ALTER TABLE sessions ADD INDEX (username);
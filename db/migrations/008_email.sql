@@ -0,0 +1,17 @@
+-- Email addresses for password resets and the single use tokens that verify
+-- them and reset passwords
+
+ALTER TABLE accounts ADD COLUMN email VARCHAR(254) NULL;
+
+ALTER TABLE accounts ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE accounts ADD INDEX (email);
+
+CREATE TABLE tokens (
+	token BINARY(32) NOT NULL PRIMARY KEY,
+	username VARCHAR(16) NOT NULL,
+	purpose VARCHAR(16) NOT NULL,
+	data VARCHAR(255) NOT NULL DEFAULT '',
+	expires DATETIME NOT NULL,
+	INDEX (username, purpose)
+);
@@ -0,0 +1,23 @@
+-- TOTP secrets with the last time step used, recovery codes, app passwords
+-- for the launcher and when a session last confirmed the user's identity
+
+ALTER TABLE accounts ADD COLUMN totp_secret VARCHAR(64) NULL;
+
+ALTER TABLE accounts ADD COLUMN totp_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE recovery_codes (
+	username VARCHAR(16) NOT NULL,
+	code BINARY(32) NOT NULL,
+	PRIMARY KEY (username, code)
+);
+
+CREATE TABLE app_passwords (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	username VARCHAR(16) NOT NULL,
+	name VARCHAR(32) NOT NULL,
+	password VARBINARY(60) NOT NULL,
+	created DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	INDEX (username)
+);
+
+ALTER TABLE sessions ADD COLUMN reauth DATETIME NULL;
@@ -0,0 +1,5 @@
+-- When and where each app password was last used
+
+ALTER TABLE app_passwords ADD COLUMN used DATETIME NULL;
+
+ALTER TABLE app_passwords ADD COLUMN ip VARCHAR(45) NOT NULL DEFAULT '';
@@ -0,0 +1,15 @@
+-- Rate limit state shared between instances
+
+CREATE TABLE ratelimit_buckets (
+	name VARCHAR(255) NOT NULL PRIMARY KEY,
+	tokens DOUBLE NOT NULL,
+	updated DATETIME(6) NOT NULL,
+	INDEX (updated)
+);
+
+CREATE TABLE ratelimit_failures (
+	name VARCHAR(255) NOT NULL PRIMARY KEY,
+	count INT NOT NULL,
+	last DATETIME(6) NOT NULL,
+	INDEX (last)
+);
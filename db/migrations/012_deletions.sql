@@ -0,0 +1,7 @@
+-- Accounts scheduled for deletion and when their grace period ends
+
+CREATE TABLE deletions (
+	username VARCHAR(16) NOT NULL PRIMARY KEY,
+	due DATETIME NOT NULL,
+	INDEX (due)
+);
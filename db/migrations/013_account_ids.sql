@@ -0,0 +1,14 @@
+-- Accounts get an id that stays the same when they're renamed, and keep the
+-- names they used to have
+
+ALTER TABLE accounts ADD COLUMN id CHAR(32) NULL;
+
+ALTER TABLE accounts ADD UNIQUE INDEX (id);
+
+CREATE TABLE name_history (
+	account CHAR(32) NOT NULL,
+	username VARCHAR(16) NOT NULL,
+	changed DATETIME NOT NULL,
+	INDEX (account),
+	INDEX (username)
+);
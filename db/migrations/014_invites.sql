@@ -0,0 +1,16 @@
+-- Invite codes for invite only registration and accounts awaiting approval
+
+CREATE TABLE invites (
+	code VARCHAR(32) NOT NULL PRIMARY KEY,
+	username VARCHAR(16) NOT NULL,
+	created DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP()),
+	expires DATETIME NULL,
+	uses INT NOT NULL DEFAULT 0,
+	max_uses INT NOT NULL,
+	INDEX (username)
+);
+
+CREATE TABLE pending_accounts (
+	username VARCHAR(16) NOT NULL PRIMARY KEY,
+	created DATETIME NOT NULL DEFAULT (UTC_TIMESTAMP())
+);
@@ -0,0 +1,229 @@
+-- Per-user data is keyed by the account's id instead of its name, so renaming
+-- only changes accounts. Every account has an id by now, see migrationSetup,
+-- and rows left behind by accounts that no longer exist are dropped.
+
+ALTER TABLE accounts MODIFY COLUMN id CHAR(32) NOT NULL;
+
+ALTER TABLE accounts DROP PRIMARY KEY;
+
+ALTER TABLE accounts ADD PRIMARY KEY (id);
+
+ALTER TABLE accounts DROP INDEX id;
+
+ALTER TABLE accounts ADD UNIQUE INDEX (username);
+
+ALTER TABLE sessions ADD COLUMN account CHAR(32) NULL AFTER id;
+
+UPDATE sessions SET account = (SELECT id FROM accounts WHERE accounts.username = sessions.username);
+
+DELETE FROM sessions WHERE account IS NULL;
+
+ALTER TABLE sessions DROP COLUMN username;
+
+ALTER TABLE sessions MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE sessions ADD INDEX (account);
+
+ALTER TABLE tickets ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE tickets SET account = (SELECT id FROM accounts WHERE accounts.username = tickets.username);
+
+DELETE FROM tickets WHERE account IS NULL;
+
+ALTER TABLE tickets DROP PRIMARY KEY;
+
+ALTER TABLE tickets DROP COLUMN username;
+
+ALTER TABLE tickets MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE tickets ADD PRIMARY KEY (account);
+
+ALTER TABLE players ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE players SET account = (SELECT id FROM accounts WHERE accounts.username = players.username);
+
+DELETE FROM players WHERE account IS NULL;
+
+ALTER TABLE players DROP PRIMARY KEY;
+
+ALTER TABLE players DROP COLUMN username;
+
+ALTER TABLE players MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE players ADD PRIMARY KEY (account);
+
+ALTER TABLE versions ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE versions SET account = (SELECT id FROM accounts WHERE accounts.username = versions.username);
+
+DELETE FROM versions WHERE account IS NULL;
+
+ALTER TABLE versions DROP PRIMARY KEY;
+
+ALTER TABLE versions DROP COLUMN username;
+
+ALTER TABLE versions MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE versions ADD PRIMARY KEY (account);
+
+ALTER TABLE cosmetics ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE cosmetics SET account = (SELECT id FROM accounts WHERE accounts.username = cosmetics.username);
+
+DELETE FROM cosmetics WHERE account IS NULL;
+
+ALTER TABLE cosmetics DROP PRIMARY KEY;
+
+ALTER TABLE cosmetics DROP COLUMN username;
+
+ALTER TABLE cosmetics MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE cosmetics ADD PRIMARY KEY (account, kind, hash);
+
+ALTER TABLE cape_grants ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE cape_grants SET account = (SELECT id FROM accounts WHERE accounts.username = cape_grants.username);
+
+DELETE FROM cape_grants WHERE account IS NULL;
+
+ALTER TABLE cape_grants DROP PRIMARY KEY;
+
+ALTER TABLE cape_grants DROP COLUMN username;
+
+ALTER TABLE cape_grants MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE cape_grants ADD PRIMARY KEY (account, cape);
+
+ALTER TABLE cape_selections ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE cape_selections SET account = (SELECT id FROM accounts WHERE accounts.username = cape_selections.username);
+
+DELETE FROM cape_selections WHERE account IS NULL;
+
+ALTER TABLE cape_selections DROP PRIMARY KEY;
+
+ALTER TABLE cape_selections DROP COLUMN username;
+
+ALTER TABLE cape_selections MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE cape_selections ADD PRIMARY KEY (account);
+
+ALTER TABLE entitlements ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE entitlements SET account = (SELECT id FROM accounts WHERE accounts.username = entitlements.username);
+
+DELETE FROM entitlements WHERE account IS NULL;
+
+ALTER TABLE entitlements DROP PRIMARY KEY;
+
+ALTER TABLE entitlements DROP COLUMN username;
+
+ALTER TABLE entitlements MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE entitlements ADD PRIMARY KEY (account, entitlement);
+
+ALTER TABLE roles ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE roles SET account = (SELECT id FROM accounts WHERE accounts.username = roles.username);
+
+DELETE FROM roles WHERE account IS NULL;
+
+ALTER TABLE roles DROP PRIMARY KEY;
+
+ALTER TABLE roles DROP COLUMN username;
+
+ALTER TABLE roles MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE roles ADD PRIMARY KEY (account);
+
+ALTER TABLE bans ADD COLUMN account CHAR(32) NULL AFTER id;
+
+UPDATE bans SET account = (SELECT id FROM accounts WHERE accounts.username = bans.username);
+
+DELETE FROM bans WHERE account IS NULL;
+
+ALTER TABLE bans DROP COLUMN username;
+
+ALTER TABLE bans MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE bans ADD INDEX (account);
+
+ALTER TABLE tokens ADD COLUMN account CHAR(32) NULL AFTER token;
+
+UPDATE tokens SET account = (SELECT id FROM accounts WHERE accounts.username = tokens.username);
+
+DELETE FROM tokens WHERE account IS NULL;
+
+ALTER TABLE tokens DROP INDEX username;
+
+ALTER TABLE tokens DROP COLUMN username;
+
+ALTER TABLE tokens MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE tokens ADD INDEX (account, purpose);
+
+ALTER TABLE recovery_codes ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE recovery_codes SET account = (SELECT id FROM accounts WHERE accounts.username = recovery_codes.username);
+
+DELETE FROM recovery_codes WHERE account IS NULL;
+
+ALTER TABLE recovery_codes DROP PRIMARY KEY;
+
+ALTER TABLE recovery_codes DROP COLUMN username;
+
+ALTER TABLE recovery_codes MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE recovery_codes ADD PRIMARY KEY (account, code);
+
+ALTER TABLE app_passwords ADD COLUMN account CHAR(32) NULL AFTER id;
+
+UPDATE app_passwords SET account = (SELECT id FROM accounts WHERE accounts.username = app_passwords.username);
+
+DELETE FROM app_passwords WHERE account IS NULL;
+
+ALTER TABLE app_passwords DROP COLUMN username;
+
+ALTER TABLE app_passwords MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE app_passwords ADD INDEX (account);
+
+ALTER TABLE deletions ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE deletions SET account = (SELECT id FROM accounts WHERE accounts.username = deletions.username);
+
+DELETE FROM deletions WHERE account IS NULL;
+
+ALTER TABLE deletions DROP PRIMARY KEY;
+
+ALTER TABLE deletions DROP COLUMN username;
+
+ALTER TABLE deletions MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE deletions ADD PRIMARY KEY (account);
+
+ALTER TABLE invites ADD COLUMN account CHAR(32) NULL AFTER code;
+
+UPDATE invites SET account = (SELECT id FROM accounts WHERE accounts.username = invites.username);
+
+DELETE FROM invites WHERE account IS NULL;
+
+ALTER TABLE invites DROP COLUMN username;
+
+ALTER TABLE invites MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE invites ADD INDEX (account);
+
+ALTER TABLE pending_accounts ADD COLUMN account CHAR(32) NULL FIRST;
+
+UPDATE pending_accounts SET account = (SELECT id FROM accounts WHERE accounts.username = pending_accounts.username);
+
+DELETE FROM pending_accounts WHERE account IS NULL;
+
+ALTER TABLE pending_accounts DROP PRIMARY KEY;
+
+ALTER TABLE pending_accounts DROP COLUMN username;
+
+ALTER TABLE pending_accounts MODIFY COLUMN account CHAR(32) NOT NULL;
+
+ALTER TABLE pending_accounts ADD PRIMARY KEY (account);
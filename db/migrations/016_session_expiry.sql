@@ -0,0 +1,3 @@
+-- Expired sessions are swept by when they were issued
+
+ALTER TABLE sessions ADD INDEX (issued);
@@ -0,0 +1,9 @@
+-- Password resets find accounts by their verified address, so an address can
+-- only be verified on one account. Addresses verified on several accounts
+-- before this have to be verified again, on whichever account they belong to.
+
+UPDATE accounts a JOIN (SELECT email FROM accounts WHERE email_verified = 1 GROUP BY email HAVING COUNT(*) > 1) d ON d.email = a.email SET a.email_verified = 0;
+
+ALTER TABLE accounts ADD COLUMN verified_email VARCHAR(254) AS (IF(email_verified, email, NULL)) STORED;
+
+ALTER TABLE accounts ADD UNIQUE INDEX (verified_email);
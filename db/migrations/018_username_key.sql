@@ -0,0 +1,10 @@
+-- Usernames are compared case-insensitively through a lowercased copy, so
+-- lookups can use an index and two accounts can't differ only by case
+
+ALTER TABLE accounts ADD COLUMN username_key VARCHAR(16) AS (LOWER(username)) STORED;
+
+ALTER TABLE accounts ADD UNIQUE INDEX (username_key);
+
+ALTER TABLE name_history ADD COLUMN username_key VARCHAR(16) AS (LOWER(username)) STORED;
+
+ALTER TABLE name_history ADD INDEX (username_key);
@@ -0,0 +1,222 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	// NameReservation is how long a previous name stays reserved for the
+	// account that used it
+	NameReservation = 30 * 24 * time.Hour
+
+	// RenameInterval is how long an account has to wait between renames
+	RenameInterval = 30 * 24 * time.Hour
+)
+
+var (
+	ErrNameTaken     = errors.New("username is taken")
+	ErrNameReserved  = errors.New("username is reserved")
+	ErrRenameTooSoon = errors.New("username was changed too recently")
+)
+
+// NameChange is a name an account used to have
+type NameChange struct {
+	Username string
+	Changed  time.Time // when the account stopped using it
+}
+
+// newAccountID returns a random version 4 UUID without dashes, the way the
+// game writes them
+func newAccountID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return hex.EncodeToString(b), nil
+}
+
+// GetAccountID returns the account's immutable ID
+func GetAccountID(ctx context.Context, username string) (string, error) {
+	var id string
+	err := conn.QueryRowContext(ctx, "SELECT id FROM accounts WHERE username = ?", username).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// assignAccountIDs gives an ID to every account made before there were IDs
+func assignAccountIDs(ctx context.Context, c *sql.Conn) error {
+	rows, err := c.QueryContext(ctx, "SELECT username FROM accounts WHERE id IS NULL OR id = ''")
+	if err != nil {
+		return err
+	}
+
+	var usernames []string
+	for rows.Next() {
+		var username string
+		err := rows.Scan(&username)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		usernames = append(usernames, username)
+	}
+
+	rows.Close()
+
+	for _, username := range usernames {
+		id, err := newAccountID()
+		if err != nil {
+			return err
+		}
+
+		_, err = c.ExecContext(ctx, "UPDATE accounts SET id = ? WHERE username = ?", id, username)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func GetUsernameFromID(ctx context.Context, id string) (string, error) {
+	var username string
+	err := conn.QueryRowContext(ctx, "SELECT username FROM accounts WHERE id = ?", id).Scan(&username)
+	if err != nil {
+		return "", err
+	}
+
+	return username, nil
+}
+
+// IsNameReserved reports whether an account other than the one with the
+// given ID recently used the name
+func IsNameReserved(ctx context.Context, username string, id string) (bool, error) {
+	var reserved bool
+	err := conn.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM name_history WHERE username_key = ? AND account != ? AND changed > ?)", UsernameKey(username), id, time.Now().UTC().Add(-NameReservation)).Scan(&reserved)
+	if err != nil {
+		return false, err
+	}
+
+	return reserved, nil
+}
+
+// GetNameHistory returns the names the account used to have, oldest first
+func GetNameHistory(ctx context.Context, id string) ([]NameChange, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT username, changed FROM name_history WHERE account = ? ORDER BY changed", id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var history []NameChange
+	for rows.Next() {
+		var change NameChange
+		err := rows.Scan(&change.Username, &change.Changed)
+		if err != nil {
+			return nil, err
+		}
+
+		history = append(history, change)
+	}
+
+	return history, nil
+}
+
+// RenameAccount changes the account's username and keeps the old one in its
+// history
+func RenameAccount(ctx context.Context, username string, newUsername string) error {
+	id, err := GetAccountID(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	// locks the account so concurrent renames can't both pass the checks
+	err = tx.QueryRowContext(ctx, "SELECT id FROM accounts WHERE id = ? FOR UPDATE", id).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	var last sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT MAX(changed) FROM name_history WHERE account = ?", id).Scan(&last)
+	if err != nil {
+		return err
+	}
+	if last.Valid && time.Since(last.Time) < RenameInterval {
+		return ErrRenameTooSoon
+	}
+
+	var owner string
+	err = tx.QueryRowContext(ctx, "SELECT id FROM accounts WHERE username_key = ?", UsernameKey(newUsername)).Scan(&owner)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil && owner != id {
+		return ErrNameTaken
+	}
+
+	var reserved bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM name_history WHERE username_key = ? AND account != ? AND changed > ?)", UsernameKey(newUsername), id, time.Now().UTC().Add(-NameReservation)).Scan(&reserved)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		return ErrNameReserved
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE accounts SET username = ? WHERE id = ?", newUsername, id)
+	if err != nil {
+		// taken by someone else since the check above
+		if isDuplicate(err) {
+			return ErrNameTaken
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO name_history (account, username, changed) VALUES (?, ?, ?)", id, username, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
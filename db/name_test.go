@@ -0,0 +1,162 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestRenameKeepsData(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+	renamed := dbtest.Username(t)
+	session := dbtest.Token(t)
+
+	id, err := db.GetAccountID(ctx, username)
+	if err != nil || id == "" {
+		t.Fatalf("new account has id %q, %v", id, err)
+	}
+
+	err = db.InsertSession(ctx, username, session, db.SessionWeb, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.SetUserRole(ctx, username, db.RoleModerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.InsertBan(ctx, username, "testing", "Admin", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.RenameAccount(ctx, username, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := db.GetAccountID(ctx, renamed)
+	if err != nil || after != id {
+		t.Errorf("renamed account has id %q, %v, want %s", after, err, id)
+	}
+
+	owner, err := db.GetUsernameFromSession(ctx, session)
+	if err != nil || owner != renamed {
+		t.Errorf("session belongs to %q, %v, want %s", owner, err, renamed)
+	}
+
+	role, err := db.GetUserRole(ctx, renamed)
+	if err != nil || role != db.RoleModerator {
+		t.Errorf("renamed account has role %q, %v, want %s", role, err, db.RoleModerator)
+	}
+
+	ban, err := db.GetActiveBan(ctx, renamed)
+	if err != nil || ban.Username != renamed {
+		t.Errorf("renamed account's ban is for %q, %v, want %s", ban.Username, err, renamed)
+	}
+
+	// nothing is left under the old name
+	_, err = db.GetActiveBan(ctx, username)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("old name is still banned: %v", err)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+	session := dbtest.Token(t)
+
+	err := db.InsertSession(ctx, username, session, db.SessionWeb, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.DeleteAccount(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.GetUsernameFromSession(ctx, session)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("session outlived its account: %v", err)
+	}
+
+	// a new account with the name doesn't inherit anything
+	err = db.InsertAccount(ctx, username, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := db.GetUserSessions(ctx, username)
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("new account has %d sessions, %v", len(sessions), err)
+	}
+}
+
+func TestRenameCaseOnly(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+	renamed := strings.ToUpper(username)
+
+	id, err := db.GetAccountID(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the account already has the name, just in another case
+	err = db.RenameAccount(ctx, username, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{username, renamed, strings.ToLower(username)} {
+		canonical, err := db.GetCanonicalUsername(ctx, name)
+		if err != nil || canonical != renamed {
+			t.Errorf("%s: got %q, %v, want %s", name, canonical, err, renamed)
+		}
+	}
+
+	after, err := db.GetAccountID(ctx, renamed)
+	if err != nil || after != id {
+		t.Errorf("renamed account has id %q, %v, want %s", after, err, id)
+	}
+
+	// its old casing is in its own history, which doesn't reserve it
+	reserved, err := db.IsNameReserved(ctx, username, id)
+	if err != nil || reserved {
+		t.Errorf("old casing is reserved from its own account: %t, %v", reserved, err)
+	}
+}
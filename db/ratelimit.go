@@ -0,0 +1,124 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// RateLimits keeps rate limiting state in the database so it's shared
+// between servers
+type RateLimits struct{}
+
+func (RateLimits) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	tokens := float64(burst)
+
+	var updated time.Time
+	err = tx.QueryRowContext(ctx, "SELECT tokens, updated FROM ratelimit_buckets WHERE name = ? FOR UPDATE", key).Scan(&tokens, &updated)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, 0, err
+	}
+	if err == nil {
+		tokens = min(float64(burst), tokens+now.Sub(updated).Seconds()*rate)
+	}
+
+	ok := tokens >= 1
+	if ok {
+		tokens--
+	}
+
+	_, err = tx.ExecContext(ctx, "REPLACE INTO ratelimit_buckets (name, tokens, updated) VALUES (?, ?, ?)", key, tokens, now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, 0, err
+	}
+
+	if !ok {
+		return false, time.Duration((1 - tokens) / rate * float64(time.Second)), nil
+	}
+
+	return true, 0, nil
+}
+
+func (RateLimits) Fail(ctx context.Context, key string, window time.Duration) error {
+	now := time.Now().UTC()
+
+	_, err := conn.ExecContext(ctx, "INSERT INTO ratelimit_failures (name, count, last) VALUES (?, 1, ?) ON DUPLICATE KEY UPDATE count = IF(last < ?, 1, count + 1), last = VALUES(last)", key, now, now.Add(-window))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (RateLimits) Failures(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	var count int
+	var last time.Time
+	err := conn.QueryRowContext(ctx, "SELECT count, last FROM ratelimit_failures WHERE name = ? AND last >= ?", key, time.Now().UTC().Add(-window)).Scan(&count, &last)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, time.Time{}, nil
+		}
+
+		return 0, time.Time{}, err
+	}
+
+	return count, last, nil
+}
+
+func (RateLimits) Reset(ctx context.Context, key string) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM ratelimit_failures WHERE name = ?", key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteStaleRateLimits removes buckets and failures nothing has touched
+// since before
+func DeleteStaleRateLimits(ctx context.Context, before time.Time) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM ratelimit_buckets WHERE updated < ?", before.UTC())
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "DELETE FROM ratelimit_failures WHERE last < ?", before.UTC())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+)
+
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// Roles lists every assignable role, the empty string being a regular user
+var Roles = []string{"", RoleModerator, RoleAdmin}
+
+const (
+	PermModerate = "moderate" // review uploaded skins and capes
+	PermCapes    = "capes"    // manage cape designs and grants
+	PermUsers    = "users"    // search users, revoke sessions and ban accounts
+	PermAccounts = "accounts" // reset passwords, force versions, delete accounts and assign roles
+)
+
+var rolePermissions = map[string][]string{
+	RoleAdmin:     {PermModerate, PermCapes, PermUsers, PermAccounts},
+	RoleModerator: {PermModerate, PermUsers},
+}
+
+// HasPermission reports whether the role grants the permission
+func HasPermission(role string, permission string) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// Outranks reports whether role ranks above other, ranks following Roles
+func Outranks(role string, other string) bool {
+	return slices.Index(Roles, role) > slices.Index(Roles, other)
+}
+
+// GetUserRole returns the user's role, or an empty string for regular users
+func GetUserRole(ctx context.Context, username string) (string, error) {
+	var role string
+	err := conn.QueryRowContext(ctx, "SELECT role FROM roles WHERE account = "+accountID, username).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return role, nil
+}
+
+// SetUserRole assigns the role to the user, an empty role making them a
+// regular user again
+func SetUserRole(ctx context.Context, username string, role string) error {
+	if role == "" {
+		_, err := conn.ExecContext(ctx, "DELETE FROM roles WHERE account = "+accountID, username)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	_, err := conn.ExecContext(ctx, "REPLACE INTO roles (account, role) VALUES ("+accountID+", ?)", username, role)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+)
+
+func TestOutranks(t *testing.T) {
+	tests := []struct {
+		role  string
+		other string
+		want  bool
+	}{
+		{db.RoleAdmin, db.RoleModerator, true},
+		{db.RoleAdmin, "", true},
+		{db.RoleModerator, "", true},
+		{db.RoleAdmin, db.RoleAdmin, false},
+		{db.RoleModerator, db.RoleModerator, false},
+		{db.RoleModerator, db.RoleAdmin, false},
+		{"", "", false},
+		{"", db.RoleModerator, false},
+	}
+
+	for _, tt := range tests {
+		if db.Outranks(tt.role, tt.other) != tt.want {
+			t.Errorf("Outranks(%q, %q) = %t, want %t", tt.role, tt.other, !tt.want, tt.want)
+		}
+	}
+}
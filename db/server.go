@@ -21,7 +21,7 @@ package db
 import "context"
 
 func SetUserServerID(ctx context.Context, username string, sid []byte) error {
-	_, err := conn.ExecContext(ctx, "REPLACE INTO players (username, server) VALUES (?, ?)", username, sid)
+	_, err := conn.ExecContext(ctx, "REPLACE INTO players (account, server) VALUES ("+accountID+", ?)", username, sid)
 	if err != nil {
 		return err
 	}
@@ -31,7 +31,7 @@ func SetUserServerID(ctx context.Context, username string, sid []byte) error {
 
 func GetUserServerID(ctx context.Context, username string) ([]byte, error) {
 	var sid []byte
-	err := conn.QueryRowContext(ctx, "SELECT server FROM players WHERE username = ? AND issued > DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 MINUTE)", username).Scan(&sid)
+	err := conn.QueryRowContext(ctx, "SELECT server FROM players WHERE account = "+accountID+" AND issued > DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 MINUTE)", username).Scan(&sid)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +40,7 @@ func GetUserServerID(ctx context.Context, username string) ([]byte, error) {
 }
 
 func DeleteUserServerID(ctx context.Context, username string) error {
-	_, err := conn.ExecContext(ctx, "DELETE FROM players WHERE username = ?", username)
+	_, err := conn.ExecContext(ctx, "DELETE FROM players WHERE account = "+accountID, username)
 	if err != nil {
 		return err
 	}
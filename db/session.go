@@ -18,10 +18,32 @@
 
 package db
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"time"
+)
 
-func InsertSession(ctx context.Context, username string, session []byte) error {
-	_, err := conn.ExecContext(ctx, "REPLACE INTO sessions (username, session) VALUES (?, ?)", username, session)
+const (
+	SessionWeb      = "web"
+	SessionLauncher = "launcher"
+)
+
+// sessionActive matches sessions that haven't expired yet. Web sessions last
+// as long as the cookie, launcher sessions only a day.
+const sessionActive = "issued > DATE_SUB(UTC_TIMESTAMP(), INTERVAL IF(type = 'web', 7, 1) DAY)"
+
+// Session is a login on the website or through the launcher
+type Session struct {
+	ID      int64
+	Type    string
+	Created time.Time
+	Used    time.Time
+	IP      string
+}
+
+func InsertSession(ctx context.Context, username string, session []byte, kind string, ip string) error {
+	_, err := conn.ExecContext(ctx, "INSERT INTO sessions (account, session, type, ip, used) VALUES ("+accountID+", ?, ?, ?, UTC_TIMESTAMP())", username, session, kind, ip)
 	if err != nil {
 		return err
 	}
@@ -31,10 +53,124 @@ func InsertSession(ctx context.Context, username string, session []byte) error {
 
 func GetUsernameFromSession(ctx context.Context, session []byte) (string, error) {
 	var username string
-	err := conn.QueryRowContext(ctx, "SELECT username FROM sessions WHERE session = ? AND issued > DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 DAY)", session).Scan(&username)
+	err := conn.QueryRowContext(ctx, "SELECT a.username FROM sessions JOIN accounts a ON a.id = sessions.account WHERE session = ? AND "+sessionActive, session).Scan(&username)
 	if err != nil {
 		return "", err
 	}
 
 	return username, nil
 }
+
+func GetSessionID(ctx context.Context, session []byte) (int64, error) {
+	var id int64
+	err := conn.QueryRowContext(ctx, "SELECT id FROM sessions WHERE session = ?", session).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// TouchSession records that the session was just used from ip
+func TouchSession(ctx context.Context, session []byte, ip string) error {
+	_, err := conn.ExecContext(ctx, "UPDATE sessions SET used = UTC_TIMESTAMP(), ip = ? WHERE session = ?", ip, session)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUserSessions returns the user's active sessions, most recently used first
+func GetUserSessions(ctx context.Context, username string) ([]Session, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT id, type, issued, used, ip FROM sessions WHERE account = "+accountID+" AND "+sessionActive+" ORDER BY used DESC", username)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		err = rows.Scan(&session.ID, &session.Type, &session.Created, &session.Used, &session.IP)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+func DeleteSession(ctx context.Context, session []byte) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM sessions WHERE session = ?", session)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUserSession revokes one of the user's sessions by id
+func DeleteUserSession(ctx context.Context, username string, id int64) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM sessions WHERE account = "+accountID+" AND id = ?", username, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUserSessions revokes every one of the user's sessions
+func DeleteUserSessions(ctx context.Context, username string) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM sessions WHERE account = "+accountID, username)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteOtherSessions revokes every one of the user's sessions except keep
+func DeleteOtherSessions(ctx context.Context, username string, keep []byte) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM sessions WHERE account = "+accountID+" AND session <> ?", username, keep)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions removes sessions that can't be used anymore
+func DeleteExpiredSessions(ctx context.Context) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM sessions WHERE NOT ("+sessionActive+")")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetSessionReauth records that the user just confirmed their identity again
+func SetSessionReauth(ctx context.Context, session []byte) error {
+	_, err := conn.ExecContext(ctx, "UPDATE sessions SET reauth = UTC_TIMESTAMP() WHERE session = ?", session)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetSessionReauth returns when the user last confirmed their identity in
+// the session, or the zero time if they haven't
+func GetSessionReauth(ctx context.Context, session []byte) (time.Time, error) {
+	var reauth sql.NullTime
+	err := conn.QueryRowContext(ctx, "SELECT reauth FROM sessions WHERE session = ?", session).Scan(&reauth)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return reauth.Time, nil
+}
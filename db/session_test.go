@@ -0,0 +1,153 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestMigrateTwice(t *testing.T) {
+	dbtest.Init(t)
+
+	// everything is applied already, so this has nothing to do
+	err := db.Migrate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	web := dbtest.Token(t)
+	launcher := dbtest.Token(t)
+
+	err := db.InsertSession(ctx, username, web, db.SessionWeb, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.InsertSession(ctx, username, launcher, db.SessionLauncher, "192.0.2.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := db.GetUserSessions(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want both", len(sessions))
+	}
+
+	owner, err := db.GetUsernameFromSession(ctx, launcher)
+	if err != nil || owner != username {
+		t.Errorf("launcher session belongs to %q, %v, want %s", owner, err, username)
+	}
+
+	reauth, err := db.GetSessionReauth(ctx, web)
+	if err != nil || !reauth.IsZero() {
+		t.Errorf("new session was reauthenticated at %s, %v", reauth, err)
+	}
+
+	err = db.SetSessionReauth(ctx, web)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reauth, err = db.GetSessionReauth(ctx, web)
+	if err != nil || reauth.IsZero() {
+		t.Errorf("reauthentication wasn't recorded: %v", err)
+	}
+
+	id, err := db.GetSessionID(ctx, web)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.DeleteUserSession(ctx, username, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.GetUsernameFromSession(ctx, web)
+	if err == nil {
+		t.Error("revoked session still works")
+	}
+
+	err = db.DeleteUserSessions(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err = db.GetUserSessions(ctx, username)
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("got %d sessions after revoking all of them, %v", len(sessions), err)
+	}
+}
+
+func TestDeleteExpiredSessions(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	tests := []struct {
+		kind string
+		age  int // hours
+		kept bool
+	}{
+		{db.SessionWeb, 0, true},
+		{db.SessionWeb, 3 * 24, true},
+		{db.SessionWeb, 8 * 24, false},
+		{db.SessionLauncher, 0, true},
+		{db.SessionLauncher, 2 * 24, false},
+	}
+
+	sessions := make([][]byte, len(tests))
+	for i, tt := range tests {
+		sessions[i] = dbtest.Token(t)
+
+		err := db.InsertSession(ctx, username, sessions[i], tt.kind, "192.0.2.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dbtest.Exec(t, "UPDATE sessions SET issued = DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? HOUR) WHERE session = ?", tt.age, sessions[i])
+	}
+
+	err := db.DeleteExpiredSessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range tests {
+		_, err := db.GetSessionID(ctx, sessions[i])
+		if (err == nil) != tt.kept {
+			t.Errorf("%s session %d hours old: kept %t, want %t (%v)", tt.kind, tt.age, err == nil, tt.kept, err)
+		}
+	}
+}
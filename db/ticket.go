@@ -18,10 +18,13 @@
 
 package db
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 func InsertTicket(ctx context.Context, username string, ticket []byte) error {
-	_, err := conn.ExecContext(ctx, "REPLACE INTO tickets (username, ticket) VALUES (?, ?)", username, ticket)
+	_, err := conn.ExecContext(ctx, "REPLACE INTO tickets (account, ticket) VALUES ("+accountID+", ?)", username, ticket)
 	if err != nil {
 		return err
 	}
@@ -31,7 +34,7 @@ func InsertTicket(ctx context.Context, username string, ticket []byte) error {
 
 func GetUsernameFromTicket(ctx context.Context, ticket []byte) (string, error) {
 	var username string
-	err := conn.QueryRowContext(ctx, "SELECT username FROM tickets WHERE ticket = ? AND issued > DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 DAY)", ticket).Scan(&username)
+	err := conn.QueryRowContext(ctx, "SELECT a.username FROM tickets t JOIN accounts a ON a.id = t.account WHERE t.ticket = ? AND t.issued > DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 DAY)", ticket).Scan(&username)
 	if err != nil {
 		return "", err
 	}
@@ -47,3 +50,23 @@ func DeleteTicket(ctx context.Context, ticket []byte) error {
 
 	return nil
 }
+
+// GetUserTicketIssued returns when the user's current ticket was issued
+func GetUserTicketIssued(ctx context.Context, username string) (time.Time, error) {
+	var issued time.Time
+	err := conn.QueryRowContext(ctx, "SELECT issued FROM tickets WHERE account = "+accountID, username).Scan(&issued)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return issued, nil
+}
+
+func DeleteUserTicket(ctx context.Context, username string) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM tickets WHERE account = "+accountID, username)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
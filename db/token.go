@@ -0,0 +1,96 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"time"
+)
+
+const (
+	TokenVerifyEmail   = "verify"
+	TokenResetPassword = "reset"
+	TokenLogin         = "login"
+)
+
+// tokens are stored hashed so a leaked table can't be used to take over
+// accounts
+func hashToken(token []byte) []byte {
+	sum := sha256.Sum256(token)
+	return sum[:]
+}
+
+// InsertToken stores a single-use token for the user that expires after
+// lifetime, replacing any earlier token with the same purpose. data is
+// returned when the token is consumed.
+func InsertToken(ctx context.Context, username string, purpose string, token []byte, data string, lifetime time.Duration) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM tokens WHERE account = "+accountID+" AND purpose = ?", username, purpose)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "INSERT INTO tokens (token, account, purpose, data, expires) VALUES (?, "+accountID+", ?, ?, ?)", hashToken(token), username, purpose, data, time.Now().UTC().Add(lifetime))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PeekToken returns the user a token belongs to without consuming it
+func PeekToken(ctx context.Context, purpose string, token []byte) (string, error) {
+	var username string
+	err := conn.QueryRowContext(ctx, "SELECT a.username FROM tokens t JOIN accounts a ON a.id = t.account WHERE t.token = ? AND t.purpose = ? AND t.expires > UTC_TIMESTAMP()", hashToken(token), purpose).Scan(&username)
+	if err != nil {
+		return "", err
+	}
+
+	return username, nil
+}
+
+// ConsumeToken deletes the token and returns the user and data it was
+// issued with. It returns sql.ErrNoRows if the token doesn't exist, has
+// expired or was already used.
+func ConsumeToken(ctx context.Context, purpose string, token []byte) (string, string, error) {
+	hash := hashToken(token)
+
+	var username, data string
+	err := conn.QueryRowContext(ctx, "SELECT a.username, t.data FROM tokens t JOIN accounts a ON a.id = t.account WHERE t.token = ? AND t.purpose = ? AND t.expires > UTC_TIMESTAMP()", hash, purpose).Scan(&username, &data)
+	if err != nil {
+		return "", "", err
+	}
+
+	// only the request that deletes it gets to use it
+	res, err := conn.ExecContext(ctx, "DELETE FROM tokens WHERE token = ?", hash)
+	if err != nil {
+		return "", "", err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return "", "", err
+	}
+	if n == 0 {
+		return "", "", sql.ErrNoRows
+	}
+
+	return username, data, nil
+}
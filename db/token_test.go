@@ -0,0 +1,133 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestTokens(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	token := dbtest.Token(t)
+
+	err := db.InsertToken(ctx, username, db.TokenVerifyEmail, token, "notch@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner, err := db.PeekToken(ctx, db.TokenVerifyEmail, token)
+	if err != nil || owner != username {
+		t.Errorf("token belongs to %q, %v, want %s", owner, err, username)
+	}
+
+	// tokens only work for what they were issued for
+	_, _, err = db.ConsumeToken(ctx, db.TokenResetPassword, token)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("verification token reset a password: %v", err)
+	}
+
+	owner, data, err := db.ConsumeToken(ctx, db.TokenVerifyEmail, token)
+	if err != nil || owner != username || data != "notch@example.com" {
+		t.Errorf("consuming returned %q, %q, %v, want %s and the address", owner, data, err, username)
+	}
+
+	// and only once
+	_, _, err = db.ConsumeToken(ctx, db.TokenVerifyEmail, token)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("token was consumed twice: %v", err)
+	}
+
+	_, err = db.PeekToken(ctx, db.TokenVerifyEmail, token)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("consumed token can still be peeked: %v", err)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	expired := dbtest.Token(t)
+
+	err := db.InsertToken(ctx, username, db.TokenResetPassword, expired, "", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.PeekToken(ctx, db.TokenResetPassword, expired)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expired token can be peeked: %v", err)
+	}
+
+	_, _, err = db.ConsumeToken(ctx, db.TokenResetPassword, expired)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expired token can be consumed: %v", err)
+	}
+}
+
+func TestTokenReplaced(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+	other := dbtest.Account(t, "correct horse")
+
+	first := dbtest.Token(t)
+	second := dbtest.Token(t)
+	theirs := dbtest.Token(t)
+
+	for _, tok := range []struct {
+		username string
+		token    []byte
+	}{{username, first}, {other, theirs}, {username, second}} {
+		err := db.InsertToken(ctx, tok.username, db.TokenResetPassword, tok.token, "", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a new link replaces the user's last one, but not anyone else's
+	_, err := db.PeekToken(ctx, db.TokenResetPassword, first)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("replaced token still works: %v", err)
+	}
+
+	owner, err := db.PeekToken(ctx, db.TokenResetPassword, second)
+	if err != nil || owner != username {
+		t.Errorf("new token belongs to %q, %v, want %s", owner, err, username)
+	}
+
+	owner, err = db.PeekToken(ctx, db.TokenResetPassword, theirs)
+	if err != nil || owner != other {
+		t.Errorf("other user's token belongs to %q, %v, want %s", owner, err, other)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+)
+
+// GetTOTP returns the user's TOTP secret and the last time step a code was
+// accepted for. The secret is empty if two-factor authentication is off.
+func GetTOTP(ctx context.Context, username string) (string, int64, error) {
+	var secret sql.NullString
+	var last int64
+	err := conn.QueryRowContext(ctx, "SELECT totp_secret, totp_step FROM accounts WHERE username = ?", username).Scan(&secret, &last)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return secret.String, last, nil
+}
+
+func EnableTOTP(ctx context.Context, username string, secret string, step int64) error {
+	_, err := conn.ExecContext(ctx, "UPDATE accounts SET totp_secret = ?, totp_step = ? WHERE username = ?", secret, step, username)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DisableTOTP turns off two-factor authentication and removes the user's
+// recovery codes
+func DisableTOTP(ctx context.Context, username string) error {
+	_, err := conn.ExecContext(ctx, "UPDATE accounts SET totp_secret = NULL, totp_step = 0 WHERE username = ?", username)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "DELETE FROM recovery_codes WHERE account = "+accountID, username)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UseTOTPStep records that a code for step was accepted. It returns false if
+// a code for the same or a later step was already used.
+func UseTOTPStep(ctx context.Context, username string, step int64) (bool, error) {
+	res, err := conn.ExecContext(ctx, "UPDATE accounts SET totp_step = ? WHERE username = ? AND totp_step < ?", step, username, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n != 0, nil
+}
+
+// recovery codes are random enough that a plain hash is sufficient
+func hashRecoveryCode(code string) []byte {
+	sum := sha256.Sum256([]byte(code))
+	return sum[:]
+}
+
+// SetRecoveryCodes replaces the user's recovery codes
+func SetRecoveryCodes(ctx context.Context, username string, codes []string) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM recovery_codes WHERE account = "+accountID, username)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		_, err = conn.ExecContext(ctx, "INSERT INTO recovery_codes (account, code) VALUES ("+accountID+", ?)", username, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes, returning false
+// if it isn't one of them
+func UseRecoveryCode(ctx context.Context, username string, code string) (bool, error) {
+	res, err := conn.ExecContext(ctx, "DELETE FROM recovery_codes WHERE account = "+accountID+" AND code = ?", username, hashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n != 0, nil
+}
+
+func CountRecoveryCodes(ctx context.Context, username string) (int, error) {
+	var count int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM recovery_codes WHERE account = "+accountID, username).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -25,7 +25,7 @@ import (
 
 func GetUserClientVersion(ctx context.Context, username string) (string, error) {
 	var version string
-	err := conn.QueryRowContext(ctx, "SELECT version FROM versions WHERE username = ?", username).Scan(&version)
+	err := conn.QueryRowContext(ctx, "SELECT version FROM versions WHERE account = "+accountID, username).Scan(&version)
 	if err != nil {
 		return "", err
 	}
@@ -35,7 +35,7 @@ func GetUserClientVersion(ctx context.Context, username string) (string, error)
 
 func GetUserClientVersionChanged(ctx context.Context, username string) (time.Time, error) {
 	var changed time.Time
-	err := conn.QueryRowContext(ctx, "SELECT changed FROM versions WHERE username = ?", username).Scan(&changed)
+	err := conn.QueryRowContext(ctx, "SELECT changed FROM versions WHERE account = "+accountID, username).Scan(&changed)
 	if err != nil {
 		return time.Now(), err
 	}
@@ -44,7 +44,7 @@ func GetUserClientVersionChanged(ctx context.Context, username string) (time.Tim
 }
 
 func SetUserClientVersion(ctx context.Context, username string, version string) error {
-	_, err := conn.ExecContext(ctx, "REPLACE INTO versions (username, version) VALUES (?, ?)", username, version)
+	_, err := conn.ExecContext(ctx, "REPLACE INTO versions (account, version) VALUES ("+accountID+", ?)", username, version)
 	if err != nil {
 		return err
 	}
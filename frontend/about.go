@@ -28,7 +28,7 @@ func About(w http.ResponseWriter, r *http.Request) {
 
 	username, err := UsernameFromRequest(r)
 	if err != nil && err != http.ErrNoCookie {
-		http.Redirect(w, r, "/logout", http.StatusSeeOther)
+		signOut(w, r)
 		return
 	}
 
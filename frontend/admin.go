@@ -0,0 +1,395 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+)
+
+const (
+	maxSearchResults = 50
+	maxAuditEntries  = 100
+)
+
+// actionPermissions maps each account action to the permission it requires
+var actionPermissions = map[string]string{
+	"revokesession": db.PermUsers,
+	"revoketicket":  db.PermUsers,
+	"ban":           db.PermUsers,
+	"unban":         db.PermUsers,
+	"resetpw":       db.PermAccounts,
+	"setversion":    db.PermAccounts,
+	"setrole":       db.PermAccounts,
+	"delete":        db.PermAccounts,
+	"approve":       db.PermAccounts,
+	"reject":        db.PermAccounts,
+}
+
+func Admin(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Admin", Page: "admin"}
+
+	var ok bool
+	ad.Username, ad.Role, ok = AdminFromRequest(w, r, db.PermUsers)
+	if !ok {
+		return
+	}
+
+	var err error
+
+	ad.Query = strings.TrimSpace(r.URL.Query().Get("q"))
+	if ad.Query != "" {
+		ad.Users, err = db.SearchAccounts(r.Context(), ad.Query, maxSearchResults)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to search users: %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	ad.PendingAccounts, err = db.GetPendingAccounts(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get pending accounts: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	ad.Audit, err = db.GetAuditLog(r.Context(), "", maxAuditEntries)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get audit log: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func AdminUser(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Manage User", Page: "adminuser", Versions: versions}
+
+	var ok bool
+	ad.Username, ad.Role, ok = AdminFromRequest(w, r, db.PermUsers)
+	if !ok {
+		return
+	}
+
+	target, err := db.GetCanonicalUsername(r.Context(), r.FormValue("username"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "The specified user doesn't exist", http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, fmt.Sprintf("failed to get user: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == "POST" {
+		action := r.PostFormValue("action")
+
+		// deleting an account can't be undone
+		if action == "delete" && !requireReauth(w, r, "/admin/user?username="+url.QueryEscape(target)) {
+			return
+		}
+
+		reason := accountAction(r, &ad, action, target)
+		if reason != "" {
+			loadAccount(r, &ad, target)
+			Error(w, ad, reason)
+			return
+		}
+
+		ad.Success = true
+
+		// there's nothing left to show
+		if action == "delete" || action == "reject" {
+			ad.Page = "admin"
+			ad.Header = "Admin"
+			ad.PendingAccounts, _ = db.GetPendingAccounts(r.Context())
+			ad.Audit, _ = db.GetAuditLog(r.Context(), "", maxAuditEntries)
+
+			err = t.Execute(w, ad)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+				return
+			}
+
+			return
+		}
+	}
+
+	err = loadAccount(r, &ad, target)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get account details: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// loadAccount fills in the target's account details and audit history
+func loadAccount(r *http.Request, ad *ActionData, target string) error {
+	account := Account{Username: target}
+
+	var err error
+
+	account.Role, err = db.GetUserRole(r.Context(), target)
+	if err != nil {
+		return err
+	}
+
+	ban, err := db.GetActiveBan(r.Context(), target)
+	if err == nil {
+		account.Ban = &ban
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	account.Bans, err = db.GetBans(r.Context(), target)
+	if err != nil {
+		return err
+	}
+
+	account.Version, err = db.GetUserClientVersion(r.Context(), target)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	account.Sessions, err = db.GetUserSessions(r.Context(), target)
+	if err != nil {
+		return err
+	}
+
+	account.TicketIssued, err = db.GetUserTicketIssued(r.Context(), target)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	account.Pending, err = db.IsAccountPending(r.Context(), target)
+	if err != nil {
+		return err
+	}
+
+	ad.Account = &account
+
+	ad.Audit, err = db.GetAuditLog(r.Context(), target, maxAuditEntries)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// accountAction performs the requested action against the target's account,
+// returning the reason it failed if it did
+func accountAction(r *http.Request, ad *ActionData, action string, target string) string {
+	permission, ok := actionPermissions[action]
+	if !ok {
+		return "Unknown action"
+	}
+	if !db.HasPermission(ad.Role, permission) {
+		return "You aren't allowed to perform this action"
+	}
+
+	// staff can't lock themselves out
+	if target == ad.Username && slices.Contains([]string{"ban", "setrole", "delete"}, action) {
+		return "You can't perform this action on your own account"
+	}
+
+	// or act on staff of the same or a higher rank
+	if target != ad.Username {
+		role, err := db.GetUserRole(r.Context(), target)
+		if err != nil {
+			return "An error occured while checking the user's role"
+		}
+		if !db.Outranks(ad.Role, role) {
+			return "You can't perform this action on staff ranking at or above you"
+		}
+	}
+
+	var detail string
+
+	switch action {
+	case "revokesession":
+		// revoke every session unless one is picked
+		if r.PostFormValue("id") == "" {
+			err := db.DeleteUserSessions(r.Context(), target)
+			if err != nil {
+				return "An error occured while revoking the sessions"
+			}
+
+			break
+		}
+
+		id, err := strconv.ParseInt(r.PostFormValue("id"), 10, 64)
+		if err != nil {
+			return "The selected session is invalid"
+		}
+
+		err = db.DeleteUserSession(r.Context(), target, id)
+		if err != nil {
+			return "An error occured while revoking the session"
+		}
+
+		detail = r.PostFormValue("id")
+	case "revoketicket":
+		err := db.DeleteUserTicket(r.Context(), target)
+		if err != nil {
+			return "An error occured while revoking the ticket"
+		}
+	case "ban":
+		reason := strings.TrimSpace(r.PostFormValue("reason"))
+		if reason == "" || len(reason) > 128 {
+			return "The ban reason must be between 1 and 128 characters"
+		}
+
+		// no duration means a permanent ban
+		var expires time.Time
+		if hours := r.PostFormValue("hours"); hours != "" {
+			h, err := strconv.Atoi(hours)
+			if err != nil || h < 1 {
+				return "The ban duration is invalid"
+			}
+
+			expires = time.Now().Add(time.Duration(h) * time.Hour)
+		}
+
+		err := db.InsertBan(r.Context(), target, reason, ad.Username, expires)
+		if err != nil {
+			return "An error occured while banning the user"
+		}
+
+		msg := revokeAccess(r, target)
+		if msg != "" {
+			return msg
+		}
+
+		detail = reason
+		if !expires.IsZero() {
+			detail += " (until " + expires.UTC().Format("2006-01-02 15:04") + " UTC)"
+		}
+	case "unban":
+		err := db.LiftBans(r.Context(), target)
+		if err != nil {
+			return "An error occured while lifting the ban"
+		}
+	case "resetpw":
+		b := make([]byte, 12)
+		_, err := rand.Read(b)
+		if err != nil {
+			return "An error occured while generating a password"
+		}
+
+		password := base64.RawURLEncoding.EncodeToString(b)
+
+		err = db.UpdatePassword(r.Context(), target, password)
+		if err != nil {
+			return "An error occured while updating the password"
+		}
+
+		reason := revokeAccess(r, target)
+		if reason != "" {
+			return reason
+		}
+
+		ad.Password = password
+	case "setversion":
+		version := r.PostFormValue("version")
+		if !slices.ContainsFunc(versions, func(v Version) bool { return v.Name == version }) {
+			return "The specified version isn't available"
+		}
+
+		err := db.SetUserClientVersion(r.Context(), target, version)
+		if err != nil {
+			return "An error occured while setting the version"
+		}
+
+		detail = version
+	case "setrole":
+		role := r.PostFormValue("role")
+		if !slices.Contains(db.Roles, role) {
+			return "The specified role doesn't exist"
+		}
+
+		err := db.SetUserRole(r.Context(), target, role)
+		if err != nil {
+			return "An error occured while setting the role"
+		}
+
+		detail = role
+	case "delete":
+		err := purgeAccount(r.Context(), target)
+		if err != nil {
+			return "An error occured while deleting the account"
+		}
+	case "approve":
+		err := db.ApproveAccount(r.Context(), target)
+		if err != nil {
+			return "An error occured while approving the account"
+		}
+	case "reject":
+		pending, err := db.IsAccountPending(r.Context(), target)
+		if err != nil {
+			return "An error occured while checking the account"
+		}
+		if !pending {
+			return "The account isn't awaiting approval"
+		}
+
+		err = purgeAccount(r.Context(), target)
+		if err != nil {
+			return "An error occured while deleting the account"
+		}
+	}
+
+	audit(r, ad.Username, action, target, detail)
+
+	return ""
+}
+
+// revokeAccess signs the user out of the website and the launcher
+func revokeAccess(r *http.Request, username string) string {
+	err := db.DeleteUserSessions(r.Context(), username)
+	if err != nil {
+		return "An error occured while revoking the sessions"
+	}
+
+	err = db.DeleteUserTicket(r.Context(), username)
+	if err != nil {
+		return "An error occured while revoking the ticket"
+	}
+
+	return ""
+}
@@ -0,0 +1,98 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestAccountActionRanks(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	admin := dbtest.Account(t, "correct horse")
+	moderator := dbtest.Account(t, "correct horse")
+	user := dbtest.Account(t, "correct horse")
+
+	err := db.SetUserRole(ctx, admin, db.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.SetUserRole(ctx, moderator, db.RoleModerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.InsertSession(ctx, admin, dbtest.Token(t), db.SessionWeb, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		actor   string
+		role    string
+		action  string
+		target  string
+		allowed bool
+	}{
+		{moderator, db.RoleModerator, "ban", admin, false},
+		{moderator, db.RoleModerator, "revokesession", admin, false},
+		{moderator, db.RoleModerator, "revoketicket", admin, false},
+		{moderator, db.RoleModerator, "ban", moderator, false},
+		{admin, db.RoleAdmin, "setrole", admin, false},
+		{moderator, db.RoleModerator, "ban", user, true},
+		{moderator, db.RoleModerator, "unban", user, true},
+		{admin, db.RoleAdmin, "revokesession", moderator, true},
+	}
+
+	for _, tt := range tests {
+		form := url.Values{"action": {tt.action}, "username": {tt.target}, "reason": {"testing"}}
+
+		r := httptest.NewRequest("POST", "/admin/user", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		ad := ActionData{Username: tt.actor, Role: tt.role}
+
+		reason := accountAction(r, &ad, tt.action, tt.target)
+		if (reason == "") != tt.allowed {
+			t.Errorf("%s %s on %s returned %q, want allowed %t", tt.role, tt.action, tt.target, reason, tt.allowed)
+		}
+	}
+
+	// nothing reached the admin's account
+	_, err = db.GetActiveBan(ctx, admin)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("admin was banned by a moderator: %v", err)
+	}
+
+	sessions, err := db.GetUserSessions(ctx, admin)
+	if err != nil || len(sessions) != 1 {
+		t.Errorf("admin has %d sessions after a moderator tried revoking them, %v", len(sessions), err)
+	}
+}
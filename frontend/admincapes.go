@@ -0,0 +1,203 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+)
+
+func AdminCapes(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Manage Capes", Page: "admincapes"}
+
+	var ok bool
+	ad.Username, ad.Role, ok = AdminFromRequest(w, r, db.PermCapes)
+	if !ok {
+		return
+	}
+
+	if r.Method == "POST" {
+		err := r.ParseMultipartForm(maxUploadSize)
+		if err != nil {
+			Error(w, ad, "An error occured while parsing your request")
+			return
+		}
+
+		reason := adminCapeAction(r, ad.Username)
+		if reason != "" {
+			ad.Capes, _ = db.GetCapes(r.Context())
+			Error(w, ad, reason)
+			return
+		}
+
+		ad.Success = true
+	}
+
+	var err error
+	ad.Capes, err = db.GetCapes(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get capes: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// adminCapeAction performs the requested cape management action, returning
+// the reason it failed if it did
+func adminCapeAction(r *http.Request, admin string) string {
+	action := r.PostFormValue("action")
+
+	if action == "create" {
+		name := strings.TrimSpace(r.PostFormValue("name"))
+		if name == "" || len(name) > 32 {
+			return "The cape name must be between 1 and 32 characters"
+		}
+
+		f, _, err := r.FormFile("image")
+		if err != nil {
+			return "An error occured while reading the image"
+		}
+
+		defer f.Close()
+
+		image, err := cosmetic.Decode(f)
+		if err != nil {
+			return "The image couldn't be decoded"
+		}
+
+		err = cosmetic.Validate(cosmetic.Cape, image)
+		if err != nil {
+			return err.Error()
+		}
+
+		hash, err := cosmetic.Store(r.Context(), image)
+		if err != nil {
+			return "An error occured while storing the image"
+		}
+
+		err = db.InsertCape(r.Context(), name, hash)
+		if err != nil {
+			return "An error occured while creating the cape"
+		}
+
+		audit(r, admin, "createcape", "", name)
+
+		return ""
+	}
+
+	// everything else targets a user or an existing cape
+	var target string
+	if action != "delete" {
+		var err error
+		target, err = db.GetCanonicalUsername(r.Context(), r.PostFormValue("username"))
+		if err != nil {
+			return "The specified user doesn't exist"
+		}
+	}
+
+	switch action {
+	case "entitle":
+		err := db.GrantEntitlement(r.Context(), target, db.EntitlementCustomCape)
+		if err != nil {
+			return "An error occured while granting the entitlement"
+		}
+
+		audit(r, admin, "entitle", target, db.EntitlementCustomCape)
+
+		return ""
+	case "unentitle":
+		err := db.RevokeEntitlement(r.Context(), target, db.EntitlementCustomCape)
+		if err != nil {
+			return "An error occured while revoking the entitlement"
+		}
+
+		audit(r, admin, "unentitle", target, db.EntitlementCustomCape)
+
+		return ""
+	}
+
+	id, err := strconv.Atoi(r.PostFormValue("cape"))
+	if err != nil {
+		return "No cape was selected"
+	}
+
+	switch action {
+	case "grant":
+		err = db.GrantCape(r.Context(), target, id)
+		if err != nil {
+			return "An error occured while granting the cape"
+		}
+	case "revoke":
+		err = db.RevokeCape(r.Context(), target, id)
+		if err != nil {
+			return "An error occured while revoking the cape"
+		}
+
+		// take it off the user if they're wearing it
+		selected, err := db.GetSelectedCape(r.Context(), target)
+		if err == nil && selected == id {
+			err = removeCape(r, target)
+			if err != nil {
+				return "An error occured while removing the user's cape"
+			}
+		}
+	case "delete":
+		wearers, err := db.GetCapeWearers(r.Context(), id)
+		if err != nil {
+			return "An error occured while finding users wearing the cape"
+		}
+
+		for _, wearer := range wearers {
+			err = removeCape(r, wearer)
+			if err != nil {
+				return "An error occured while removing the cape from its wearers"
+			}
+		}
+
+		err = db.DeleteCape(r.Context(), id)
+		if err != nil {
+			return "An error occured while deleting the cape"
+		}
+	default:
+		return "Unknown action"
+	}
+
+	audit(r, admin, action+"cape", target, strconv.Itoa(id))
+
+	return ""
+}
+
+func removeCape(r *http.Request, username string) error {
+	err := cosmetic.Remove(r.Context(), cosmetic.Cape, username)
+	if err != nil {
+		return err
+	}
+
+	return db.ClearSelectedCape(r.Context(), username)
+}
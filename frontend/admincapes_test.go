@@ -0,0 +1,176 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"image/color"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+// insertCape creates a cape design of the given colour and returns its id
+func insertCape(t *testing.T, c color.NRGBA) int {
+	t.Helper()
+
+	ctx := context.Background()
+
+	hash, err := cosmetic.Store(ctx, solidImage(64, 32, c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := dbtest.Username(t)
+
+	err = db.InsertCape(ctx, name, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capes, err := db.GetCapes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cape := range capes {
+		if cape.Name == name {
+			return cape.ID
+		}
+	}
+
+	t.Fatalf("cape %s wasn't created", name)
+	return 0
+}
+
+func selectCapeRequest(t *testing.T, username string, id int) string {
+	t.Helper()
+
+	r := multipartRequest(t, "/setcape", map[string]string{"cape": strconv.Itoa(id)})
+	signIn(t, r, username)
+
+	w := httptest.NewRecorder()
+	SetCosmetic(w, r)
+
+	return w.Body.String()
+}
+
+func capeAction(t *testing.T, action string, username string, id int) {
+	t.Helper()
+
+	form := url.Values{"action": {action}, "username": {username}, "cape": {strconv.Itoa(id)}}
+
+	r := httptest.NewRequest("POST", "/admin/capes", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	reason := adminCapeAction(r, "Admin")
+	if reason != "" {
+		t.Fatalf("%s cape %d for %s: %s", action, id, username, reason)
+	}
+}
+
+func TestCapeGrants(t *testing.T) {
+	dbtest.Init(t)
+	initStorage(t)
+
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	red := color.NRGBA{255, 0, 0, 255}
+	green := color.NRGBA{0, 255, 0, 255}
+
+	worn := insertCape(t, red)
+	other := insertCape(t, green)
+
+	// capes that weren't granted can't be worn
+	body := selectCapeRequest(t, username, worn)
+	if !strings.Contains(body, "been granted to you") {
+		t.Error("selecting an ungranted cape wasn't refused")
+	}
+
+	_, ok := publishedColor(t, cosmetic.Cape, username)
+	if ok {
+		t.Error("an ungranted cape was published")
+	}
+
+	_, err := db.GetSelectedCape(ctx, username)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("an ungranted cape was selected: %v", err)
+	}
+
+	capeAction(t, "grant", username, worn)
+	capeAction(t, "grant", username, other)
+
+	selectCapeRequest(t, username, worn)
+
+	published, ok := publishedColor(t, cosmetic.Cape, username)
+	if !ok || published != red {
+		t.Errorf("published cape is %v, %t, want %v", published, ok, red)
+	}
+
+	// revoking a cape the user isn't wearing leaves theirs alone
+	capeAction(t, "revoke", username, other)
+
+	published, ok = publishedColor(t, cosmetic.Cape, username)
+	if !ok || published != red {
+		t.Errorf("published cape is %v, %t after revoking another cape, want %v", published, ok, red)
+	}
+
+	// revoking the one they wear takes it off them
+	capeAction(t, "revoke", username, worn)
+
+	_, ok = publishedColor(t, cosmetic.Cape, username)
+	if ok {
+		t.Error("a revoked cape is still published")
+	}
+
+	_, err = db.GetSelectedCape(ctx, username)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("a revoked cape is still selected: %v", err)
+	}
+
+	capes, err := db.GetUserCapes(ctx, username)
+	if err != nil || len(capes) != 0 {
+		t.Errorf("user has %d capes after both were revoked, %v", len(capes), err)
+	}
+
+	body = selectCapeRequest(t, username, worn)
+	if !strings.Contains(body, "been granted to you") {
+		t.Error("selecting a revoked cape wasn't refused")
+	}
+
+	// so does deleting the design
+	capeAction(t, "grant", username, other)
+	selectCapeRequest(t, username, other)
+	capeAction(t, "delete", "", other)
+
+	_, ok = publishedColor(t, cosmetic.Cape, username)
+	if ok {
+		t.Error("a deleted cape is still published")
+	}
+}
@@ -0,0 +1,127 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+)
+
+func AdminModeration(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Moderation", Page: "adminmoderation"}
+
+	var ok bool
+	ad.Username, ad.Role, ok = AdminFromRequest(w, r, db.PermModerate)
+	if !ok {
+		return
+	}
+
+	if r.Method == "POST" {
+		reason := moderate(r, ad.Username)
+		if reason != "" {
+			ad.History, _ = db.GetPendingCosmetics(r.Context())
+			Error(w, ad, reason)
+			return
+		}
+
+		ad.Success = true
+	}
+
+	var err error
+	ad.History, err = db.GetPendingCosmetics(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get pending cosmetics: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// moderate approves or rejects a pending upload, returning the reason it
+// failed if it did
+func moderate(r *http.Request, admin string) string {
+	username := r.PostFormValue("username")
+	kind := cosmetic.Kind(r.PostFormValue("kind"))
+	hash := r.PostFormValue("hash")
+
+	status, err := db.GetCosmeticStatus(r.Context(), username, string(kind), hash)
+	if err != nil {
+		return "The selected upload doesn't exist"
+	}
+	if status != db.CosmeticPending {
+		return "The selected upload isn't pending review"
+	}
+
+	switch r.PostFormValue("action") {
+	case "approve":
+		// an upload that was followed by another one only becomes
+		// restorable, approving it mustn't replace what the user has now
+		latest, err := db.IsLatestCosmetic(r.Context(), username, string(kind), hash)
+		if err != nil {
+			return "An error occured while reading the user's history"
+		}
+
+		if latest {
+			image, err := cosmetic.Load(r.Context(), hash)
+			if err != nil {
+				return "An error occured while reading the image"
+			}
+
+			// published first so the upload stays pending, and can be
+			// approved again, if publishing fails
+			err = publishCosmetic(r.Context(), kind, username, image)
+			if err != nil {
+				return "An error occured while publishing the upload"
+			}
+		}
+
+		err = db.SetCosmeticStatus(r.Context(), username, string(kind), hash, db.CosmeticApproved, "")
+		if err != nil {
+			return "An error occured while approving the upload"
+		}
+	case "reject":
+		reason := strings.TrimSpace(r.PostFormValue("reason"))
+		if reason == "" || len(reason) > 255 {
+			return "The rejection reason must be between 1 and 255 characters"
+		}
+
+		err = db.SetCosmeticStatus(r.Context(), username, string(kind), hash, db.CosmeticRejected, reason)
+		if err != nil {
+			return "An error occured while rejecting the upload"
+		}
+
+		audit(r, admin, "reject"+string(kind), username, hash+": "+reason)
+
+		return ""
+	default:
+		return "Unknown action"
+	}
+
+	audit(r, admin, "approve"+string(kind), username, hash)
+
+	return ""
+}
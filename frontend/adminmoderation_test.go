@@ -0,0 +1,94 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"image/color"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func moderateUpload(username string, hash string, action string) string {
+	form := url.Values{"username": {username}, "kind": {string(cosmetic.Skin)}, "hash": {hash}, "action": {action}, "reason": {"testing"}}
+
+	r := httptest.NewRequest("POST", "/admin/moderation", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return moderate(r, "Admin")
+}
+
+func TestModerateLatest(t *testing.T) {
+	dbtest.Init(t)
+	initStorage(t)
+
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	red := color.NRGBA{255, 0, 0, 255}
+	green := color.NRGBA{0, 255, 0, 255}
+
+	older := storeCosmetic(t, username, cosmetic.Skin, solidImage(64, 32, red), db.CosmeticPending)
+
+	// upload times only have second precision
+	time.Sleep(time.Second)
+
+	newer := storeCosmetic(t, username, cosmetic.Skin, solidImage(64, 32, green), db.CosmeticPending)
+
+	// approving the newest upload publishes it
+	reason := moderateUpload(username, newer, "approve")
+	if reason != "" {
+		t.Fatalf("approving the newer upload failed: %s", reason)
+	}
+
+	published, ok := publishedColor(t, cosmetic.Skin, username)
+	if !ok || published != green {
+		t.Errorf("published skin is %v, %t, want %v", published, ok, green)
+	}
+
+	// approving an older one afterwards only makes it restorable
+	reason = moderateUpload(username, older, "approve")
+	if reason != "" {
+		t.Fatalf("approving the older upload failed: %s", reason)
+	}
+
+	published, ok = publishedColor(t, cosmetic.Skin, username)
+	if !ok || published != green {
+		t.Errorf("published skin is %v, %t after approving an older upload, want %v", published, ok, green)
+	}
+
+	status, err := db.GetCosmeticStatus(ctx, username, string(cosmetic.Skin), older)
+	if err != nil || status != db.CosmeticApproved {
+		t.Errorf("older upload is %q, %v, want approved", status, err)
+	}
+
+	// decisions are final
+	reason = moderateUpload(username, older, "reject")
+	if !strings.Contains(reason, "pending review") {
+		t.Errorf("rejecting an approved upload returned %q", reason)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/patapancakes/betablock/db"
+)
+
+// maxAppPasswords caps how many app passwords an account can have, since the
+// launcher login has to try each of them
+const maxAppPasswords = 10
+
+func AppPasswords(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "App Passwords", Page: "apppasswords"}
+
+	var err error
+	ad.Username, err = UsernameFromRequest(r)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		signOut(w, r)
+		return
+	}
+
+	if !requireReauth(w, r, "/apppasswords") {
+		return
+	}
+
+	secret, _, err := db.GetTOTP(r.Context(), ad.Username)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get two-factor status: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	ad.TwoFactor = secret != ""
+
+	if r.Method == "POST" {
+		reason := appPasswordAction(r, &ad)
+		if reason != "" {
+			ad.AppPasswords, _ = db.GetAppPasswords(r.Context(), ad.Username)
+			Error(w, ad, reason)
+			return
+		}
+
+		ad.Success = true
+	}
+
+	ad.AppPasswords, err = db.GetAppPasswords(r.Context(), ad.Username)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get app passwords: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// appPasswordAction creates or revokes an app password, returning the reason
+// it failed if it did
+func appPasswordAction(r *http.Request, ad *ActionData) string {
+	switch r.PostFormValue("action") {
+	case "create":
+		name := strings.TrimSpace(r.PostFormValue("name"))
+		if name == "" || len(name) > 32 {
+			return "The name must be between 1 and 32 characters"
+		}
+
+		passwords, err := db.GetAppPasswords(r.Context(), ad.Username)
+		if err != nil {
+			return "An error occured while getting your app passwords"
+		}
+
+		if len(passwords) >= maxAppPasswords {
+			return fmt.Sprintf("You can't have more than %d app passwords", maxAppPasswords)
+		}
+
+		b := make([]byte, 12)
+		_, err = rand.Read(b)
+		if err != nil {
+			return "An error occured while generating the password"
+		}
+
+		password := base64.RawURLEncoding.EncodeToString(b)
+
+		err = db.InsertAppPassword(r.Context(), ad.Username, name, password)
+		if err != nil {
+			return "An error occured while saving the password"
+		}
+
+		ad.Password = password
+	case "revoke":
+		id, err := strconv.ParseInt(r.PostFormValue("id"), 10, 64)
+		if err != nil {
+			return "The selected app password is invalid"
+		}
+
+		err = db.DeleteAppPassword(r.Context(), ad.Username, id)
+		if err != nil {
+			return "An error occured while revoking the password"
+		}
+	default:
+		return "Unknown action"
+	}
+
+	return ""
+}
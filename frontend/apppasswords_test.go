@@ -0,0 +1,69 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestAppPasswordCap(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	create := func() string {
+		form := url.Values{"action": {"create"}, "name": {"launcher"}}
+
+		r := httptest.NewRequest("POST", "/apppasswords", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		session := signIn(t, r, username)
+
+		err := db.SetSessionReauth(ctx, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		AppPasswords(w, r)
+
+		return w.Body.String()
+	}
+
+	for range maxAppPasswords {
+		create()
+	}
+
+	body := create()
+	if !strings.Contains(body, "more than") {
+		t.Error("created an app password past the cap")
+	}
+
+	passwords, err := db.GetAppPasswords(ctx, username)
+	if err != nil || len(passwords) != maxAppPasswords {
+		t.Errorf("got %d app passwords, %v, want %d", len(passwords), err, maxAppPasswords)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/patapancakes/betablock/challenge"
+)
+
+// Challenged makes requests matched by policy pass the configured challenge
+// before reaching next
+func Challenged(policy challenge.Policy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !challenge.Enabled() || !policy(r) {
+			next(w, r)
+			return
+		}
+
+		ok, err := challenge.Verify(r)
+		if err == nil && ok {
+			next(w, r)
+			return
+		}
+
+		ad := ActionData{Header: "Verification Failed", Page: "challenge", Next: r.URL.Path}
+
+		ad.Username, _ = UsernameFromRequest(r)
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			ad.Error = "An error occured while verifying your browser, please try again"
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			ad.Error = "Verification failed, please try again"
+		}
+
+		err = t.Execute(w, ad)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// LoginPolicy skips the challenge for the second factor step, which already
+// passed it with the password
+func LoginPolicy(r *http.Request) bool {
+	return challenge.Posts(r) && r.PostFormValue("token") == ""
+}
@@ -19,15 +19,38 @@
 package frontend
 
 import (
-	"database/sql"
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/patapancakes/betablock/db"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/patapancakes/betablock/passwords"
 )
 
+// newPassword reads a new password and its confirmation from the form,
+// returning a reason if it can't be used
+func newPassword(r *http.Request, field string, username string) (string, string) {
+	password := r.PostFormValue(field)
+	if password != r.PostFormValue("confirm") {
+		return "", "The passwords don't match"
+	}
+
+	err := passwords.Check(username, password)
+	switch err {
+	case nil:
+		return password, ""
+	case passwords.ErrTooShort:
+		return "", fmt.Sprintf("The password must be at least %d characters", passwords.MinLength)
+	case passwords.ErrTooLong:
+		return "", fmt.Sprintf("The password can't be longer than %d bytes", passwords.MaxLength)
+	case passwords.ErrUsername:
+		return "", "The password can't be your username"
+	case passwords.ErrCommon:
+		return "", "The password is too common, please choose another"
+	default:
+		return "", "The password can't be used"
+	}
+}
+
 func ChangePW(w http.ResponseWriter, r *http.Request) {
 	ad := ActionData{Header: "Change Password", Page: "changepw"}
 
@@ -38,12 +61,16 @@ func ChangePW(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		http.Redirect(w, r, "/logout", http.StatusSeeOther)
+		signOut(w, r)
 		return
 	}
 
 	ad.Username = username
 
+	if !requireReauth(w, r, "/changepw") {
+		return
+	}
+
 	if r.Method == "GET" {
 		err := t.Execute(w, ad)
 		if err != nil {
@@ -54,38 +81,30 @@ func ChangePW(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if os.Getenv("TS_SITE_KEY") != "" {
-		ok, err := verifyTurnstile(r)
-		if err != nil {
-			Error(w, ad, "Server error")
-			return
-		}
-		if !ok {
-			Error(w, ad, "Verification failed")
-			return
-		}
+	password, reason := newPassword(r, "newpassword", username)
+	if reason != "" {
+		Error(w, ad, reason)
+		return
 	}
 
-	// validate username and password
-	err = db.ValidatePassword(r.Context(), ad.Username, r.PostFormValue("password"))
+	err = db.UpdatePassword(r.Context(), username, password)
 	if err != nil {
-		var reason string
-		switch err {
-		case sql.ErrNoRows:
-			reason = "The specified user doesn't exist"
-		case bcrypt.ErrMismatchedHashAndPassword:
-			reason = "The password is incorrect"
-		default:
-			reason = "An unknown error occured during account validation"
-		}
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
 
-		Error(w, ad, reason)
+	// whoever knew the old password shouldn't stay signed in elsewhere
+	session, _ := sessionFromRequest(r)
+
+	err = db.DeleteOtherSessions(r.Context(), username, session)
+	if err != nil {
+		Error(w, ad, "An error occured while signing out your other sessions")
 		return
 	}
 
-	err = db.UpdatePassword(r.Context(), username, r.PostFormValue("newpassword"))
+	err = db.DeleteUserTicket(r.Context(), username)
 	if err != nil {
-		http.Error(w, "Server error", http.StatusInternalServerError)
+		Error(w, ad, "An error occured while signing out your other sessions")
 		return
 	}
 
@@ -21,14 +21,19 @@ package frontend
 import (
 	"context"
 	"embed"
-	"encoding/base64"
+	"fmt"
 	"html/template"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/patapancakes/betablock/challenge"
 	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/passwords"
+	"github.com/patapancakes/betablock/proxy"
 )
 
 type ActionData struct {
@@ -36,11 +41,52 @@ type ActionData struct {
 	Header  string
 	Error   string
 	Success bool
+	Pending bool
 
 	Username string
+	Role     string
 	Version  string
 
+	Preview template.URL
+	History []db.CosmeticEntry
+
+	Capes      []db.Cape
+	CustomCape bool
+
 	Versions []Version
+
+	Query    string
+	Users    []string
+	Account  *Account
+	Audit    []db.AuditEntry
+	Password string
+
+	Ban *db.Ban
+
+	Sessions       []db.Session
+	CurrentSession int64
+
+	Email         string
+	EmailVerified bool
+	Token         string
+
+	TwoFactor     bool
+	Secret        string
+	QR            template.URL
+	RecoveryCodes []string
+	RecoveryCount int
+	AppPasswords  []db.AppPassword
+	Next          string
+	Deletion      time.Time
+
+	Names           []db.NameChange
+	RenameAvailable time.Time
+
+	Invite          string
+	Invites         []db.Invite
+	PendingAccounts []db.PendingAccount
+
+	CSRF string
 }
 
 type Version struct {
@@ -48,11 +94,35 @@ type Version struct {
 	Time time.Time
 }
 
+// Account is a user's details as shown to admins
+type Account struct {
+	Username     string
+	Role         string
+	Ban          *db.Ban
+	Bans         []db.Ban
+	Version      string
+	Sessions     []db.Session
+	TicketIssued time.Time
+	Pending      bool
+}
+
 const maxUploadSize = 1024 * 16
 
 //go:embed templates
 var templatesFS embed.FS
-var t = template.Must(template.New("main.html").Funcs(template.FuncMap{"env": os.Getenv, "usercount": usercount, "rtversion": rtversion, "nicever": nicever}).ParseFS(templatesFS, "templates/*.html"))
+
+// pages executes the page templates with the data every page needs filled in
+type pages struct {
+	*template.Template
+}
+
+func (p pages) Execute(w http.ResponseWriter, ad ActionData) error {
+	ad.CSRF = csrfToken(w)
+
+	return p.Template.Execute(w, ad)
+}
+
+var t = pages{template.Must(template.New("main.html").Funcs(template.FuncMap{"env": os.Getenv, "challenge": challenge.Widget, "challengehead": challenge.Head, "minpassword": func() int { return passwords.MinLength }, "registration": registrationMode, "usercount": usercount, "rtversion": rtversion, "nicever": nicever, "can": db.HasPermission, "roles": func() []string { return db.Roles }}).ParseFS(templatesFS, "templates/*.html"))}
 
 //go:embed assets
 var AssetsFS embed.FS
@@ -67,12 +137,13 @@ func Error(w http.ResponseWriter, ad ActionData, reason string) error {
 	return nil
 }
 
+// sessionFromRequest returns the session token in the request's cookie
+func sessionFromRequest(r *http.Request) ([]byte, error) {
+	return readCookie(r, "session")
+}
+
 func UsernameFromRequest(r *http.Request) (string, error) {
-	sessionCookie, err := r.Cookie("session")
-	if err != nil {
-		return "", err
-	}
-	session, err := base64.StdEncoding.DecodeString(sessionCookie.Value)
+	session, err := sessionFromRequest(r)
 	if err != nil {
 		return "", err
 	}
@@ -81,9 +152,55 @@ func UsernameFromRequest(r *http.Request) (string, error) {
 		return "", err
 	}
 
+	db.TouchSession(r.Context(), session, proxy.ClientIP(r))
+
 	return username, nil
 }
 
+// AdminFromRequest returns the username and role of the staff member making
+// the request. Requests from anyone whose role doesn't grant the permission
+// are redirected or rejected and ok is false.
+func AdminFromRequest(w http.ResponseWriter, r *http.Request, permission string) (username string, role string, ok bool) {
+	username, err := UsernameFromRequest(r)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return "", "", false
+		}
+
+		signOut(w, r)
+		return "", "", false
+	}
+
+	role, err = db.GetUserRole(r.Context(), username)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return "", "", false
+	}
+	if !db.HasPermission(role, permission) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return "", "", false
+	}
+
+	return username, role, true
+}
+
+// tooManyAttempts tells the user they've been rate limited and for how long
+func tooManyAttempts(w http.ResponseWriter, ad ActionData, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	Error(w, ad, fmt.Sprintf("Too many attempts, try again in %s", wait.Round(time.Second)))
+}
+
+// audit records an action performed by an admin against target
+func audit(r *http.Request, admin string, action string, target string, detail string) {
+	err := db.InsertAuditEntry(r.Context(), admin, action, target, detail)
+	if err != nil {
+		log.Printf("failed to write audit entry for %s %s %s: %s", admin, action, target, err)
+	}
+}
+
 func usercount() int {
 	count, _ := db.GetUserCount(context.TODO())
 	return count
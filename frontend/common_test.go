@@ -0,0 +1,86 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+	"github.com/patapancakes/betablock/storage"
+)
+
+// signIn gives the request a new web session for username and returns it
+func signIn(t *testing.T, r *http.Request, username string) []byte {
+	t.Helper()
+
+	session := dbtest.Token(t)
+
+	err := db.InsertSession(context.Background(), username, session, db.SessionWeb, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(session)
+	r.AddCookie(&http.Cookie{Name: "session", Value: encoded + "." + signCookie("session", encoded)})
+
+	return session
+}
+
+// initStorage points the storage package at an empty directory
+func initStorage(t *testing.T) {
+	t.Helper()
+
+	s, err := storage.NewLocal(t.TempDir(), storage.SymlinksRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage.Init(s)
+}
+
+// multipartRequest builds a POST of the fields as a multipart form
+func multipartRequest(t *testing.T, target string, fields map[string]string) *http.Request {
+	t.Helper()
+
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	for k, v := range fields {
+		err := mw.WriteField(k, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := mw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", target, body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return r
+}
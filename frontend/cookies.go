@@ -0,0 +1,141 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var errBadCookie = errors.New("cookie signature is invalid")
+
+// CookieOptions are the attributes of every cookie the website sets
+type CookieOptions struct {
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+}
+
+var (
+	cookieKey     []byte
+	cookieOptions = CookieOptions{Secure: true, SameSite: http.SameSiteLaxMode}
+)
+
+// InitCookies sets the key cookie values are signed with and the attributes
+// cookies get. A random key is used if it's empty, but then cookies don't
+// carry over restarts or other servers.
+func InitCookies(key []byte, options CookieOptions) error {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+
+		_, err := rand.Read(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(key) < 32 {
+		return fmt.Errorf("cookie key must be at least 32 bytes")
+	}
+
+	// browsers drop these otherwise
+	if options.SameSite == http.SameSiteNoneMode && !options.Secure {
+		return fmt.Errorf("SameSite=None cookies have to be secure")
+	}
+
+	cookieKey = key
+	cookieOptions = options
+
+	return nil
+}
+
+// ParseSameSite parses a SameSite attribute, empty being lax
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("unknown SameSite mode %q", s)
+	}
+}
+
+// signCookie returns the signature of a cookie's value, which covers the name
+// so values can't be moved between cookies
+func signCookie(name string, value string) string {
+	mac := hmac.New(sha256.New, cookieKey)
+	mac.Write([]byte(name + "=" + value))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// setCookie sets a signed cookie, a zero maxAge making it last until the
+// browser closes
+func setCookie(w http.ResponseWriter, name string, value []byte, maxAge int) {
+	encoded := base64.RawURLEncoding.EncodeToString(value)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    encoded + "." + signCookie(name, encoded),
+		Path:     "/",
+		Domain:   cookieOptions.Domain,
+		MaxAge:   maxAge,
+		Secure:   cookieOptions.Secure,
+		HttpOnly: true,
+		SameSite: cookieOptions.SameSite,
+	})
+}
+
+// clearCookie tells the browser to delete a cookie
+func clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     "/",
+		Domain:   cookieOptions.Domain,
+		MaxAge:   -1,
+		Secure:   cookieOptions.Secure,
+		HttpOnly: true,
+		SameSite: cookieOptions.SameSite,
+	})
+}
+
+// readCookie returns the value of a signed cookie, or http.ErrNoCookie if
+// it isn't set
+func readCookie(r *http.Request, name string) ([]byte, error) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signCookie(name, encoded))) {
+		return nil, errBadCookie
+	}
+
+	return base64.RawURLEncoding.DecodeString(encoded)
+}
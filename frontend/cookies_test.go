@@ -0,0 +1,152 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// cookieRequest returns a request carrying the cookies the response set
+func cookieRequest(w *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+
+	return r
+}
+
+func TestReadCookie(t *testing.T) {
+	w := httptest.NewRecorder()
+	setCookie(w, "session", []byte("value"), 0)
+
+	r := cookieRequest(w)
+
+	value, err := readCookie(r, "session")
+	if err != nil || string(value) != "value" {
+		t.Fatalf("got %q, %v, want value", value, err)
+	}
+
+	_, err = readCookie(r, "csrf")
+	if !errors.Is(err, http.ErrNoCookie) {
+		t.Errorf("missing cookie: got %v, want %v", err, http.ErrNoCookie)
+	}
+
+	signed := r.Cookies()[0].Value
+	encoded, signature, _ := strings.Cut(signed, ".")
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"unsigned", encoded},
+		{"empty signature", encoded + "."},
+		{"changed value", "dmFsdWF." + signature},
+		{"changed signature", encoded + "." + strings.ToUpper(signature)},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: "session", Value: tt.value})
+
+		_, err := readCookie(r, "session")
+		if !errors.Is(err, errBadCookie) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, errBadCookie)
+		}
+	}
+
+	// the signature covers the name, so values can't be moved between cookies
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "csrf", Value: signed})
+
+	_, err = readCookie(r, "csrf")
+	if !errors.Is(err, errBadCookie) {
+		t.Errorf("moved cookie: got %v, want %v", err, errBadCookie)
+	}
+}
+
+func TestCookieAttributes(t *testing.T) {
+	w := httptest.NewRecorder()
+	setCookie(w, "session", []byte("value"), 60)
+	clearCookie(w, "csrf")
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies, want 2", len(cookies))
+	}
+
+	for _, c := range cookies {
+		if !c.HttpOnly || c.Secure != cookieOptions.Secure || c.SameSite != cookieOptions.SameSite || c.Path != "/" {
+			t.Errorf("%s cookie has attributes %+v", c.Name, c)
+		}
+	}
+
+	if cookies[0].MaxAge != 60 || cookies[1].MaxAge >= 0 {
+		t.Errorf("got max ages %d and %d, want 60 and a deletion", cookies[0].MaxAge, cookies[1].MaxAge)
+	}
+}
+
+func TestInitCookies(t *testing.T) {
+	key, options := cookieKey, cookieOptions
+	t.Cleanup(func() { cookieKey, cookieOptions = key, options })
+
+	tests := []struct {
+		name    string
+		key     []byte
+		options CookieOptions
+		ok      bool
+	}{
+		{"random key", nil, CookieOptions{Secure: true, SameSite: http.SameSiteLaxMode}, true},
+		{"short key", []byte("short"), CookieOptions{Secure: true}, false},
+		{"insecure SameSite=None", make([]byte, 32), CookieOptions{SameSite: http.SameSiteNoneMode}, false},
+		{"secure SameSite=None", make([]byte, 32), CookieOptions{Secure: true, SameSite: http.SameSiteNoneMode}, true},
+	}
+
+	for _, tt := range tests {
+		err := InitCookies(tt.key, tt.options)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestParseSameSite(t *testing.T) {
+	tests := []struct {
+		in   string
+		want http.SameSite
+		ok   bool
+	}{
+		{"", http.SameSiteLaxMode, true},
+		{"Lax", http.SameSiteLaxMode, true},
+		{"strict", http.SameSiteStrictMode, true},
+		{"NONE", http.SameSiteNoneMode, true},
+		{"sometimes", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSameSite(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("%q: got %v, %v", tt.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// maxFormSize is the largest form body read, enough for the launcher jar
+// uploaded on the download page
+const maxFormSize = 1024 * 1024 * 5
+
+// csrfWriter carries the request's CSRF token to the templates
+type csrfWriter struct {
+	http.ResponseWriter
+	token string
+}
+
+func (w *csrfWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// csrfToken returns the CSRF token for the response, forms rendered outside
+// of the CSRF middleware get none and will be rejected
+func csrfToken(w http.ResponseWriter) string {
+	cw, ok := w.(*csrfWriter)
+	if !ok {
+		return ""
+	}
+
+	return cw.token
+}
+
+// CSRF rejects form posts that didn't come from one of the website's own
+// pages. Every visitor gets a random token in a signed cookie that's also put
+// in every form, which another site can't read to forge.
+func CSRF(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := readCookie(r, "csrf")
+		if err != nil || len(token) != 32 {
+			token = make([]byte, 32)
+
+			_, err := rand.Read(token)
+			if err != nil {
+				http.Error(w, "Server error", http.StatusInternalServerError)
+				return
+			}
+
+			setCookie(w, "csrf", token, 0)
+		}
+
+		encoded := base64.RawURLEncoding.EncodeToString(token)
+
+		// the token is in the body, so reading it has to be bounded
+		if r.Method == "POST" {
+			r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+
+			err = parseForm(r)
+
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "The form is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+		}
+
+		if r.Method == "POST" && (!sameOrigin(r) || subtle.ConstantTimeCompare([]byte(r.PostFormValue("csrf")), []byte(encoded)) != 1) {
+			ad := ActionData{Header: "Error", Next: r.URL.Path}
+
+			ad.Username, _ = UsernameFromRequest(r)
+
+			w.WriteHeader(http.StatusForbidden)
+
+			err := Error(&csrfWriter{ResponseWriter: w, token: encoded}, ad, "The form has expired, please go back and try again")
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+				return
+			}
+
+			return
+		}
+
+		next(&csrfWriter{ResponseWriter: w, token: encoded}, r)
+	}
+}
+
+// parseForm parses the request's form, whether it's urlencoded or multipart
+func parseForm(r *http.Request) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		return r.ParseMultipartForm(maxUploadSize)
+	}
+
+	return r.ParseForm()
+}
+
+// sameOrigin reports whether the browser says the request came from this
+// site, requests from browsers that don't say are left to the token check
+func sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return u.Host == r.Host
+}
@@ -0,0 +1,165 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+// csrfCookie returns a signed csrf cookie and the token forms have to carry
+func csrfCookie() (*http.Cookie, string) {
+	w := httptest.NewRecorder()
+	setCookie(w, "csrf", bytes.Repeat([]byte{7}, 32), 0)
+
+	return w.Result().Cookies()[0], base64.RawURLEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+}
+
+func TestCSRF(t *testing.T) {
+	dbtest.Init(t)
+
+	cookie, token := csrfCookie()
+
+	form := func(token string) *http.Request {
+		r := httptest.NewRequest("POST", "/changepw", strings.NewReader(url.Values{"csrf": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(cookie)
+
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		want    int
+	}{
+		{"get", func() *http.Request { return httptest.NewRequest("GET", "/changepw", nil) }, http.StatusOK},
+		{"valid", func() *http.Request { return form(token) }, http.StatusOK},
+		{"missing token", func() *http.Request { return form("") }, http.StatusForbidden},
+		{"mismatched token", func() *http.Request { return form(strings.ToUpper(token)) }, http.StatusForbidden},
+		{"no cookie", func() *http.Request {
+			r := httptest.NewRequest("POST", "/changepw", strings.NewReader(url.Values{"csrf": {token}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			return r
+		}, http.StatusForbidden},
+		{"forged cookie", func() *http.Request {
+			r := httptest.NewRequest("POST", "/changepw", strings.NewReader(url.Values{"csrf": {token}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.AddCookie(&http.Cookie{Name: "csrf", Value: token + ".forged"})
+
+			return r
+		}, http.StatusForbidden},
+		{"same origin", func() *http.Request {
+			r := form(token)
+			r.Header.Set("Origin", "http://example.com")
+			r.Header.Set("Sec-Fetch-Site", "same-origin")
+
+			return r
+		}, http.StatusOK},
+		{"other origin", func() *http.Request {
+			r := form(token)
+			r.Header.Set("Origin", "https://evil.example")
+
+			return r
+		}, http.StatusForbidden},
+		{"cross site", func() *http.Request {
+			r := form(token)
+			r.Header.Set("Sec-Fetch-Site", "cross-site")
+
+			return r
+		}, http.StatusForbidden},
+		{"multipart", func() *http.Request {
+			r := multipartRequest(t, "/setskin", map[string]string{"csrf": token})
+			r.AddCookie(cookie)
+
+			return r
+		}, http.StatusOK},
+		{"too large", func() *http.Request {
+			body := new(bytes.Buffer)
+			mw := multipart.NewWriter(body)
+			mw.WriteField("csrf", token)
+			f, _ := mw.CreateFormFile("launcher", "minecraft.jar")
+			f.Write(make([]byte, maxFormSize))
+			mw.Close()
+
+			r := httptest.NewRequest("POST", "/download", body)
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+			r.AddCookie(cookie)
+
+			return r
+		}, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		var called bool
+		handler := CSRF(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+
+		w := httptest.NewRecorder()
+		handler(w, tt.request())
+
+		if w.Code != tt.want || called != (tt.want == http.StatusOK) {
+			t.Errorf("%s: got %d, called %t, want %d", tt.name, w.Code, called, tt.want)
+		}
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		site   string
+		want   bool
+	}{
+		{"", "", true},
+		{"null", "", true},
+		{"http://example.com", "", true},
+		{"http://example.com", "same-origin", true},
+		{"", "none", true},
+		{"http://example.com:8080", "", false},
+		{"http://other.example", "", false},
+		{"", "same-site", false},
+		{"", "cross-site", false},
+		{"://", "", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if tt.site != "" {
+			r.Header.Set("Sec-Fetch-Site", tt.site)
+		}
+
+		got := sameOrigin(r)
+		if got != tt.want {
+			t.Errorf("origin %q, site %q: got %t, want %t", tt.origin, tt.site, got, tt.want)
+		}
+	}
+}
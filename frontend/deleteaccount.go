@@ -0,0 +1,186 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+)
+
+func DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Delete Account", Page: "deleteaccount"}
+
+	var err error
+	ad.Username, err = UsernameFromRequest(r)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		signOut(w, r)
+		return
+	}
+
+	if !requireReauth(w, r, "/delete") {
+		return
+	}
+
+	if r.Method == "POST" {
+		reason := deletionAction(w, r, &ad)
+		if reason != "" {
+			ad.Deletion, _ = db.GetScheduledDeletion(r.Context(), ad.Username)
+			Error(w, ad, reason)
+			return
+		}
+
+		ad.Success = true
+	}
+
+	// the user was signed out when scheduling the deletion
+	if ad.Username != "" {
+		ad.Deletion, err = db.GetScheduledDeletion(r.Context(), ad.Username)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, fmt.Sprintf("failed to get scheduled deletion: %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// deletionAction schedules or cancels the deletion of the user's account,
+// returning the reason it failed if it did
+func deletionAction(w http.ResponseWriter, r *http.Request, ad *ActionData) string {
+	switch r.PostFormValue("action") {
+	case "delete":
+		if r.PostFormValue("confirm") != ad.Username {
+			return "Type your username to confirm the deletion"
+		}
+
+		err := db.ScheduleDeletion(r.Context(), ad.Username)
+		if err != nil {
+			return "An error occured while scheduling the deletion"
+		}
+
+		ad.Deletion, err = db.GetScheduledDeletion(r.Context(), ad.Username)
+		if err != nil {
+			return "An error occured while scheduling the deletion"
+		}
+
+		// signing back in is how the deletion gets cancelled
+		reason := revokeAccess(r, ad.Username)
+		if reason != "" {
+			return reason
+		}
+
+		clearCookie(w, "session")
+
+		ad.Username = ""
+	case "cancel":
+		err := db.CancelDeletion(r.Context(), ad.Username)
+		if err != nil {
+			return "An error occured while cancelling the deletion"
+		}
+	default:
+		return "Unknown action"
+	}
+
+	return ""
+}
+
+// afterLogin returns where to send a user who just signed in, users with a
+// pending deletion are shown it so they can cancel it
+func afterLogin(r *http.Request, username string) string {
+	_, err := db.GetScheduledDeletion(r.Context(), username)
+	if err == nil {
+		return "/delete"
+	}
+
+	return "/"
+}
+
+// purgeAccount deletes the account along with everything stored for it,
+// including uploaded images nobody else uses. Storage is cleared first so the
+// name can't be registered again while the old images are still published
+// under it, and the account stays scheduled for deletion until all of it is
+// gone so a failure is retried.
+func purgeAccount(ctx context.Context, username string) error {
+	for _, kind := range []cosmetic.Kind{cosmetic.Skin, cosmetic.Cape} {
+		history, err := db.GetCosmeticHistory(ctx, username, string(kind))
+		if err != nil {
+			return err
+		}
+
+		err = cosmetic.Remove(ctx, kind, username)
+		if err != nil {
+			return err
+		}
+
+		// identical images are shared, so only ones nobody else uses can go
+		for _, entry := range history {
+			referenced, err := db.IsCosmeticReferenced(ctx, entry.Hash, username)
+			if err != nil {
+				return err
+			}
+			if referenced {
+				continue
+			}
+
+			err = cosmetic.Discard(ctx, entry.Hash)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return db.DeleteAccount(ctx, username)
+}
+
+// PurgeDeletedAccounts deletes the accounts whose deletion grace period is
+// over
+func PurgeDeletedAccounts(ctx context.Context) error {
+	usernames, err := db.GetDueDeletions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, username := range usernames {
+		err := purgeAccount(ctx, username)
+		if err != nil {
+			log.Printf("failed to delete account %s: %s", username, err)
+			continue
+		}
+
+		log.Printf("deleted account %s", username)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+	"github.com/patapancakes/betablock/storage"
+)
+
+// failingDeletes is a store whose deletes fail while fail is set
+type failingDeletes struct {
+	storage.Store
+	fail bool
+}
+
+func (s *failingDeletes) Delete(ctx context.Context, key string) error {
+	if s.fail {
+		return errors.New("storage is unavailable")
+	}
+
+	return s.Store.Delete(ctx, key)
+}
+
+// scheduleDeletion schedules the account's deletion with the given due time
+func scheduleDeletion(t *testing.T, username string, due time.Time) {
+	t.Helper()
+
+	err := db.ScheduleDeletion(context.Background(), username)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbtest.Exec(t, "UPDATE deletions SET due = ? WHERE account = (SELECT id FROM accounts WHERE username = ?)", due.UTC(), username)
+}
+
+func TestPurgeDeletedAccounts(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	local, err := storage.NewLocal(t.TempDir(), storage.SymlinksRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &failingDeletes{Store: local, fail: true}
+	storage.Init(s)
+
+	username := dbtest.Account(t, "correct horse")
+	other := dbtest.Account(t, "correct horse")
+	pending := dbtest.Account(t, "correct horse")
+
+	red := solidImage(64, 32, color.NRGBA{255, 0, 0, 255})
+
+	shared := storeCosmetic(t, username, cosmetic.Skin, red, db.CosmeticApproved)
+	storeCosmetic(t, other, cosmetic.Skin, red, db.CosmeticApproved)
+	own := storeCosmetic(t, username, cosmetic.Cape, solidImage(64, 32, randomColor(t)), db.CosmeticApproved)
+
+	err = cosmetic.Publish(ctx, cosmetic.Skin, username, red)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheduleDeletion(t, username, time.Now().Add(-time.Minute))
+	scheduleDeletion(t, pending, time.Now().Add(time.Hour))
+
+	// nothing is released while storage can't be cleared
+	err = PurgeDeletedAccounts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.GetAccountID(ctx, username)
+	if err != nil {
+		t.Fatalf("account was deleted before its images: %s", err)
+	}
+
+	_, err = db.GetScheduledDeletion(ctx, username)
+	if err != nil {
+		t.Fatalf("deletion was forgotten after failing: %s", err)
+	}
+
+	// and the next purge finishes the job
+	s.fail = false
+
+	err = PurgeDeletedAccounts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.GetAccountID(ctx, username)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("account wasn't deleted: %v", err)
+	}
+
+	_, ok := publishedColor(t, cosmetic.Skin, username)
+	if ok {
+		t.Error("skin is still published under the deleted name")
+	}
+
+	_, err = storage.Stat(ctx, cosmetic.ObjectKey(own))
+	if !errors.Is(err, storage.ErrNotExist) {
+		t.Errorf("unshared image wasn't removed: %v", err)
+	}
+
+	_, err = storage.Stat(ctx, cosmetic.ObjectKey(shared))
+	if err != nil {
+		t.Errorf("image another account uses was removed: %s", err)
+	}
+
+	// accounts still in their grace period are left alone
+	_, err = db.GetAccountID(ctx, pending)
+	if err != nil {
+		t.Errorf("account was deleted during its grace period: %s", err)
+	}
+}
+
+// randomColor returns an opaque colour no other test's images are likely to
+// share, since identical images are stored once for every account
+func randomColor(t *testing.T) color.NRGBA {
+	t.Helper()
+
+	b := dbtest.Token(t)
+
+	return color.NRGBA{b[0], b[1], b[2], 255}
+}
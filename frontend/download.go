@@ -24,7 +24,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path"
 	"strings"
 
@@ -36,7 +35,7 @@ func Download(w http.ResponseWriter, r *http.Request) {
 
 	username, err := UsernameFromRequest(r)
 	if err != nil && err != http.ErrNoCookie {
-		http.Redirect(w, r, "/logout", http.StatusSeeOther)
+		signOut(w, r)
 		return
 	}
 
@@ -52,18 +51,6 @@ func Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if os.Getenv("TS_SITE_KEY") != "" {
-		ok, err := verifyTurnstile(r)
-		if err != nil {
-			Error(w, ad, "Server error")
-			return
-		}
-		if !ok {
-			Error(w, ad, "Verification failed")
-			return
-		}
-	}
-
 	const maxSize = 1024 * 1024 * 4 // 4MB
 
 	f, fh, err := r.FormFile("launcher")
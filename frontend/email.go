@@ -0,0 +1,408 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+	bmail "github.com/patapancakes/betablock/mail"
+	"github.com/patapancakes/betablock/proxy"
+	"github.com/patapancakes/betablock/ratelimit"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	verifyLifetime = time.Hour * 24
+	resetLifetime  = time.Hour
+)
+
+// siteURL returns the address links in emails point to. It's configured
+// rather than taken from the request so the Host header can't redirect
+// tokens elsewhere.
+func siteURL() string {
+	if url := os.Getenv("SITE_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+
+	return "https://betablock.net"
+}
+
+func isValidEmail(email string) bool {
+	if len(email) > 254 {
+		return false
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return false
+	}
+
+	// no display names or comments
+	return addr.Address == email
+}
+
+// newToken returns a random token and its hex encoding for use in links
+func newToken() ([]byte, string, error) {
+	token := make([]byte, 32)
+	_, err := rand.Read(token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return token, hex.EncodeToString(token), nil
+}
+
+// sendVerification emails a link that verifies the user owns email
+func sendVerification(ctx context.Context, username string, email string) error {
+	token, encoded, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	err = db.InsertToken(ctx, username, db.TokenVerifyEmail, token, email, verifyLifetime)
+	if err != nil {
+		return err
+	}
+
+	return bmail.Send(ctx, bmail.Message{
+		To:      email,
+		Subject: "Verify your Betablock email address",
+		Body:    fmt.Sprintf("Hi %s,\n\nOpen the link below to verify this email address. It expires in 24 hours.\n\n%s/verify?token=%s\n\nIf you didn't add this address to a Betablock account, you can ignore this email.\n", username, siteURL(), encoded),
+	})
+}
+
+// sendReset emails a link that lets the user choose a new password
+func sendReset(ctx context.Context, username string, email string) error {
+	token, encoded, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	err = db.InsertToken(ctx, username, db.TokenResetPassword, token, "", resetLifetime)
+	if err != nil {
+		return err
+	}
+
+	return bmail.Send(ctx, bmail.Message{
+		To:      email,
+		Subject: "Reset your Betablock password",
+		Body:    fmt.Sprintf("Hi %s,\n\nOpen the link below to choose a new password. It expires in an hour and can only be used once.\n\n%s/reset?token=%s\n\nIf you didn't ask for this, you can ignore this email and your password won't change.\n", username, siteURL(), encoded),
+	})
+}
+
+func Email(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Email", Page: "email"}
+
+	var err error
+	ad.Username, err = UsernameFromRequest(r)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		signOut(w, r)
+		return
+	}
+
+	ad.Email, ad.EmailVerified, err = db.GetEmail(r.Context(), ad.Username)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get email: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == "GET" {
+		err := t.Execute(w, ad)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		return
+	}
+
+	if !bmail.Enabled() {
+		Error(w, ad, "Email isn't available on this server")
+		return
+	}
+
+	switch r.PostFormValue("action") {
+	case "set":
+		// a stolen session shouldn't allow guessing the password
+		wait, err := ratelimit.CheckLogin(r.Context(), proxy.ClientIP(r), ad.Username)
+		if err != nil {
+			Error(w, ad, "Server error")
+			return
+		}
+		if wait != 0 {
+			tooManyAttempts(w, ad, wait)
+			return
+		}
+
+		err = db.ValidatePassword(r.Context(), ad.Username, r.PostFormValue("password"))
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				ratelimit.LoginFailed(r.Context(), proxy.ClientIP(r), ad.Username)
+				Error(w, ad, "The password is incorrect")
+				return
+			}
+
+			Error(w, ad, "An unknown error occured during account validation")
+			return
+		}
+
+		ratelimit.LoginSucceeded(r.Context(), ad.Username)
+
+		email := strings.TrimSpace(r.PostFormValue("email"))
+		if email != "" && !isValidEmail(email) {
+			Error(w, ad, "The email address specified is invalid")
+			return
+		}
+
+		err = db.SetEmail(r.Context(), ad.Username, email)
+		if err != nil {
+			Error(w, ad, "An error occured while updating your email address")
+			return
+		}
+
+		ad.Email, ad.EmailVerified = email, false
+	case "resend":
+		if ad.Email == "" || ad.EmailVerified {
+			Error(w, ad, "There's no email address to verify")
+			return
+		}
+	default:
+		Error(w, ad, "Unknown action")
+		return
+	}
+
+	if ad.Email != "" {
+		err = sendVerification(r.Context(), ad.Username, ad.Email)
+		if err != nil {
+			Error(w, ad, "An error occured while sending the verification email")
+			return
+		}
+	}
+
+	ad.Success = true
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func Verify(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Verify Email", Page: "verify"}
+
+	ad.Username, _ = UsernameFromRequest(r)
+
+	token, err := hex.DecodeString(r.URL.Query().Get("token"))
+	if err != nil {
+		Error(w, ad, "The verification link is invalid")
+		return
+	}
+
+	username, email, err := db.ConsumeToken(r.Context(), db.TokenVerifyEmail, token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			Error(w, ad, "The verification link is invalid or has expired")
+			return
+		}
+
+		Error(w, ad, "An error occured while checking the verification link")
+		return
+	}
+
+	// the address may have changed since the link was sent
+	ok, err := db.VerifyEmail(r.Context(), username, email)
+	if err != nil {
+		if errors.Is(err, db.ErrEmailTaken) {
+			Error(w, ad, "The email address is already verified on another account")
+			return
+		}
+
+		Error(w, ad, "An error occured while verifying your email address")
+		return
+	}
+	if !ok {
+		Error(w, ad, "The email address has changed since the link was sent")
+		return
+	}
+
+	ad.Success = true
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func Forgot(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Forgot Password", Page: "forgot"}
+
+	if r.Method == "GET" {
+		err := t.Execute(w, ad)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		return
+	}
+
+	if !bmail.Enabled() {
+		Error(w, ad, "Password resets aren't available on this server")
+		return
+	}
+
+	// accept either a username or a verified address, and don't reveal
+	// which accounts exist or have an address
+	var username, email string
+
+	login := strings.TrimSpace(r.PostFormValue("login"))
+	if strings.Contains(login, "@") {
+		var err error
+		username, err = db.GetUsernameFromEmail(r.Context(), login)
+		if err == nil {
+			email = login
+		}
+	} else {
+		canonical, err := db.GetCanonicalUsername(r.Context(), login)
+		if err == nil {
+			address, verified, err := db.GetEmail(r.Context(), canonical)
+			if err == nil && verified {
+				username, email = canonical, address
+			}
+		}
+	}
+
+	if email != "" {
+		err := sendReset(r.Context(), username, email)
+		if err != nil {
+			Error(w, ad, "An error occured while sending the reset email")
+			return
+		}
+	}
+
+	ad.Success = true
+
+	err := t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func Reset(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Reset Password", Page: "reset"}
+
+	ad.Token = r.FormValue("token")
+
+	token, err := hex.DecodeString(ad.Token)
+	if err != nil {
+		ad.Token = ""
+		Error(w, ad, "The reset link is invalid")
+		return
+	}
+
+	if r.Method == "GET" {
+		_, err = db.PeekToken(r.Context(), db.TokenResetPassword, token)
+		if err != nil {
+			ad.Token = ""
+			Error(w, ad, "The reset link is invalid or has expired")
+			return
+		}
+
+		err = t.Execute(w, ad)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		return
+	}
+
+	// the token is only consumed once the new password is acceptable
+	username, err := db.PeekToken(r.Context(), db.TokenResetPassword, token)
+	if err != nil {
+		ad.Token = ""
+		if errors.Is(err, sql.ErrNoRows) {
+			Error(w, ad, "The reset link is invalid or has expired")
+			return
+		}
+
+		Error(w, ad, "An error occured while checking the reset link")
+		return
+	}
+
+	password, reason := newPassword(r, "password", username)
+	if reason != "" {
+		Error(w, ad, reason)
+		return
+	}
+
+	username, _, err = db.ConsumeToken(r.Context(), db.TokenResetPassword, token)
+	if err != nil {
+		ad.Token = ""
+		if errors.Is(err, sql.ErrNoRows) {
+			Error(w, ad, "The reset link is invalid or has expired")
+			return
+		}
+
+		Error(w, ad, "An error occured while checking the reset link")
+		return
+	}
+
+	ad.Token = ""
+
+	err = db.UpdatePassword(r.Context(), username, password)
+	if err != nil {
+		Error(w, ad, "An error occured while updating your password")
+		return
+	}
+
+	// whoever knew the old password shouldn't stay signed in
+	reason = revokeAccess(r, username)
+	if reason != "" {
+		Error(w, ad, reason)
+		return
+	}
+
+	ad.Success = true
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
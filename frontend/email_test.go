@@ -0,0 +1,217 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+	bmail "github.com/patapancakes/betablock/mail"
+	"github.com/patapancakes/betablock/ratelimit"
+)
+
+func TestVerifyLink(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	owner := dbtest.Account(t, "correct horse")
+	other := dbtest.Account(t, "correct horse")
+
+	email := owner + "@example.com"
+	for _, username := range []string{owner, other} {
+		err := db.SetEmail(ctx, username, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token := dbtest.Token(t)
+
+	err := db.InsertToken(ctx, owner, db.TokenVerifyEmail, token, email, verifyLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the link verifies the account it was sent for, whoever opens it
+	for _, want := range []string{"", "invalid or has expired"} {
+		r := httptest.NewRequest("GET", "/verify?token="+hex.EncodeToString(token), nil)
+		signIn(t, r, other)
+
+		w := httptest.NewRecorder()
+		Verify(w, r)
+
+		if want != "" && !strings.Contains(w.Body.String(), want) {
+			t.Errorf("opening the link again doesn't say %q", want)
+		}
+	}
+
+	for username, want := range map[string]bool{owner: true, other: false} {
+		_, verified, err := db.GetEmail(ctx, username)
+		if err != nil || verified != want {
+			t.Errorf("%s verified %t, %v, want %t", username, verified, err, want)
+		}
+	}
+}
+
+func TestResetLink(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	err := db.InsertSession(ctx, username, dbtest.Token(t), db.SessionWeb, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := dbtest.Token(t)
+
+	err = db.InsertToken(ctx, username, db.TokenResetPassword, token, "", resetLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reset := func(password string) string {
+		form := url.Values{"token": {hex.EncodeToString(token)}, "password": {password}, "confirm": {password}}
+
+		r := httptest.NewRequest("POST", "/reset", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		Reset(w, r)
+
+		return w.Body.String()
+	}
+
+	// an unusable password leaves the link working
+	body := reset("short")
+	if !strings.Contains(body, "at least") {
+		t.Error("a short password was accepted")
+	}
+
+	reset("battery staple horse")
+
+	err = db.ValidatePassword(ctx, username, "battery staple horse")
+	if err != nil {
+		t.Errorf("password wasn't reset: %s", err)
+	}
+
+	sessions, err := db.GetUserSessions(ctx, username)
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("%d sessions survived the reset, %v", len(sessions), err)
+	}
+
+	body = reset("another staple horse")
+	if !strings.Contains(body, "invalid or has expired") {
+		t.Error("the reset link worked twice")
+	}
+
+	err = db.ValidatePassword(ctx, username, "battery staple horse")
+	if err != nil {
+		t.Errorf("reusing the link changed the password: %s", err)
+	}
+}
+
+func TestEmailSetRateLimit(t *testing.T) {
+	dbtest.Init(t)
+
+	ratelimit.Init(ratelimit.NewMemory())
+	t.Cleanup(func() { ratelimit.Init(ratelimit.NewMemory()) })
+
+	mailer, err := bmail.NewLog(filepath.Join(t.TempDir(), "mail.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bmail.Init(mailer)
+	t.Cleanup(func() { bmail.Init(nil) })
+
+	username := dbtest.Account(t, "correct horse")
+
+	var code int
+	for range ratelimit.AccountLockout.Threshold + 1 {
+		form := url.Values{"action": {"set"}, "email": {username + "@example.com"}, "password": {"wrong horse"}}
+
+		r := httptest.NewRequest("POST", "/email", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		signIn(t, r, username)
+
+		w := httptest.NewRecorder()
+		Email(w, r)
+
+		code = w.Code
+	}
+
+	if code != http.StatusTooManyRequests {
+		t.Errorf("repeated wrong passwords got %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestChangePWRevokesSessions(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	other := dbtest.Token(t)
+
+	err := db.InsertSession(ctx, username, other, db.SessionLauncher, "192.0.2.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"newpassword": {"battery staple horse"}, "confirm": {"battery staple horse"}}
+
+	r := httptest.NewRequest("POST", "/changepw", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	current := signIn(t, r, username)
+
+	err = db.SetSessionReauth(ctx, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	ChangePW(w, r)
+
+	err = db.ValidatePassword(ctx, username, "battery staple horse")
+	if err != nil {
+		t.Fatalf("password wasn't changed: %s", err)
+	}
+
+	// the session that changed it stays signed in
+	_, err = db.GetUsernameFromSession(ctx, current)
+	if err != nil {
+		t.Errorf("current session was revoked: %s", err)
+	}
+
+	_, err = db.GetUsernameFromSession(ctx, other)
+	if err == nil {
+		t.Error("other session survived the password change")
+	}
+
+}
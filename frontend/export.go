@@ -0,0 +1,294 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/storage"
+)
+
+type exportProfile struct {
+	ID             string    `json:"id"`
+	Username       string    `json:"username"`
+	PreviousNames  []string  `json:"previous_names,omitempty"`
+	Role           string    `json:"role,omitempty"`
+	Email          string    `json:"email,omitempty"`
+	EmailVerified  bool      `json:"email_verified"`
+	TwoFactor      bool      `json:"two_factor"`
+	Version        string    `json:"version,omitempty"`
+	VersionChanged time.Time `json:"version_changed,omitzero"`
+	Capes          []string  `json:"capes,omitempty"`
+	SelectedCape   string    `json:"selected_cape,omitempty"`
+}
+
+type exportCosmetic struct {
+	Kind     string    `json:"kind"`
+	File     string    `json:"file"`
+	Uploaded time.Time `json:"uploaded"`
+	Status   string    `json:"status"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+type exportSession struct {
+	Type    string    `json:"type"`
+	Created time.Time `json:"created"`
+	Used    time.Time `json:"used"`
+	IP      string    `json:"ip"`
+}
+
+type exportAppPassword struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Used    time.Time `json:"used,omitzero"`
+	IP      string    `json:"ip,omitempty"`
+}
+
+type exportBan struct {
+	Reason  string    `json:"reason"`
+	Issued  time.Time `json:"issued"`
+	Expires time.Time `json:"expires,omitzero"`
+	Lifted  bool      `json:"lifted"`
+}
+
+func Export(w http.ResponseWriter, r *http.Request) {
+	username, err := UsernameFromRequest(r)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		signOut(w, r)
+		return
+	}
+
+	if !requireReauth(w, r, "/export") {
+		return
+	}
+
+	// built in memory so a failure can still be reported properly
+	buf := new(bytes.Buffer)
+	err = exportAccount(r.Context(), username, buf)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to export account: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"betablock-%s.zip\"", username))
+
+	w.Write(buf.Bytes())
+}
+
+// exportAccount writes a zip of everything stored about username to w
+func exportAccount(ctx context.Context, username string, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	profile := exportProfile{Username: username}
+
+	var err error
+	profile.ID, err = db.GetAccountID(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	names, err := db.GetNameHistory(ctx, profile.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range names {
+		profile.PreviousNames = append(profile.PreviousNames, change.Username)
+	}
+
+	profile.Role, err = db.GetUserRole(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	profile.Email, profile.EmailVerified, err = db.GetEmail(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	secret, _, err := db.GetTOTP(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	profile.TwoFactor = secret != ""
+
+	profile.Version, err = db.GetUserClientVersion(ctx, username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil {
+		profile.VersionChanged, err = db.GetUserClientVersionChanged(ctx, username)
+		if err != nil {
+			return err
+		}
+	}
+
+	capes, err := db.GetUserCapes(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	selected, err := db.GetSelectedCape(ctx, username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	for _, cape := range capes {
+		profile.Capes = append(profile.Capes, cape.Name)
+		if cape.ID == selected {
+			profile.SelectedCape = cape.Name
+		}
+	}
+
+	err = writeJSON(zw, "profile.json", profile)
+	if err != nil {
+		return err
+	}
+
+	// cosmetics, along with the images themselves
+	var cosmetics []exportCosmetic
+	for _, kind := range []cosmetic.Kind{cosmetic.Skin, cosmetic.Cape} {
+		history, err := db.GetCosmeticHistory(ctx, username, string(kind))
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range history {
+			file := "cosmetics/" + entry.Kind + "-" + entry.Hash + ".png"
+
+			cosmetics = append(cosmetics, exportCosmetic{Kind: entry.Kind, File: file, Uploaded: entry.Uploaded, Status: entry.Status, Reason: entry.Reason})
+
+			err = copyObject(ctx, zw, file, cosmetic.ObjectKey(entry.Hash))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = writeJSON(zw, "cosmetics.json", cosmetics)
+	if err != nil {
+		return err
+	}
+
+	// sessions and app passwords
+	userSessions, err := db.GetUserSessions(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	var sessions []exportSession
+	for _, s := range userSessions {
+		sessions = append(sessions, exportSession{Type: s.Type, Created: s.Created, Used: s.Used, IP: s.IP})
+	}
+
+	err = writeJSON(zw, "sessions.json", sessions)
+	if err != nil {
+		return err
+	}
+
+	userAppPasswords, err := db.GetAppPasswords(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	var appPasswords []exportAppPassword
+	for _, p := range userAppPasswords {
+		appPasswords = append(appPasswords, exportAppPassword{Name: p.Name, Created: p.Created, Used: p.Used, IP: p.IP})
+	}
+
+	err = writeJSON(zw, "app_passwords.json", appPasswords)
+	if err != nil {
+		return err
+	}
+
+	// moderation history, the issuer is left out since they're another user
+	userBans, err := db.GetBans(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	var bans []exportBan
+	for _, b := range userBans {
+		bans = append(bans, exportBan{Reason: b.Reason, Issued: b.Issued, Expires: b.Expires, Lifted: b.Lifted})
+	}
+
+	err = writeJSON(zw, "bans.json", bans)
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+
+	return enc.Encode(v)
+}
+
+// copyObject adds the stored object at key to the zip, objects that are gone
+// are skipped
+func copyObject(ctx context.Context, zw *zip.Writer, name string, key string) error {
+	obj, _, err := storage.Open(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	defer obj.Close()
+
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, obj)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
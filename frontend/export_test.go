@@ -0,0 +1,151 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/color"
+	"io"
+	"testing"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+	"github.com/patapancakes/betablock/storage"
+)
+
+// readZip returns the contents of each file in the zip
+func readZip(t *testing.T, b []byte) map[string][]byte {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return files
+}
+
+func TestExportAccount(t *testing.T) {
+	dbtest.Init(t)
+	initStorage(t)
+
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+
+	hash := storeCosmetic(t, username, cosmetic.Skin, solidImage(64, 32, color.NRGBA{255, 0, 0, 255}), db.CosmeticApproved)
+
+	err := db.InsertSession(ctx, username, dbtest.Token(t), db.SessionLauncher, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.InsertAppPassword(ctx, username, "laptop", "laptop password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.SetEmail(ctx, username, username+"@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	err = exportAccount(ctx, username, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := readZip(t, buf.Bytes())
+
+	var profile exportProfile
+	err = json.Unmarshal(files["profile.json"], &profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Username != username || profile.Email != username+"@example.com" || profile.ID == "" {
+		t.Errorf("profile is %+v", profile)
+	}
+
+	var cosmetics []exportCosmetic
+	err = json.Unmarshal(files["cosmetics.json"], &cosmetics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cosmetics) != 1 || cosmetics[0].Status != db.CosmeticApproved {
+		t.Fatalf("cosmetics are %+v", cosmetics)
+	}
+
+	// the image itself is included
+	obj, _, err := storage.Open(ctx, cosmetic.ObjectKey(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer obj.Close()
+
+	stored, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(files[cosmetics[0].File], stored) {
+		t.Errorf("%s doesn't match the stored image", cosmetics[0].File)
+	}
+
+	var sessions []exportSession
+	err = json.Unmarshal(files["sessions.json"], &sessions)
+	if err != nil || len(sessions) != 1 || sessions[0].IP != "192.0.2.1" {
+		t.Errorf("sessions are %+v, %v", sessions, err)
+	}
+
+	// app passwords are listed without the passwords
+	var appPasswords []exportAppPassword
+	err = json.Unmarshal(files["app_passwords.json"], &appPasswords)
+	if err != nil || len(appPasswords) != 1 || appPasswords[0].Name != "laptop" {
+		t.Errorf("app passwords are %+v, %v", appPasswords, err)
+	}
+	for name, b := range files {
+		if bytes.Contains(b, []byte("laptop password")) || bytes.Contains(b, []byte("correct horse")) {
+			t.Errorf("%s contains a password", name)
+		}
+	}
+
+	_, ok := files["bans.json"]
+	if !ok {
+		t.Error("bans.json is missing")
+	}
+}
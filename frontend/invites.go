@@ -0,0 +1,179 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+)
+
+// registration modes, set with REGISTRATION_MODE
+const (
+	RegistrationOpen     = "open"
+	RegistrationInvite   = "invite"
+	RegistrationApproval = "approval"
+	RegistrationClosed   = "closed"
+)
+
+var RegistrationModes = []string{RegistrationOpen, RegistrationInvite, RegistrationApproval, RegistrationClosed}
+
+// limits for invites made by users, admins can make any. moderators can see
+// and delete everyone's invites but make theirs within the same limits.
+const (
+	maxUserInvites  = 3
+	userInviteUses  = 1
+	userInviteHours = 24 * 7
+)
+
+// registrationMode returns how new accounts can be made, open if unset
+func registrationMode() string {
+	mode := os.Getenv("REGISTRATION_MODE")
+	if mode == "" {
+		return RegistrationOpen
+	}
+
+	return mode
+}
+
+func Invites(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Invites", Page: "invites"}
+
+	var err error
+	ad.Username, err = UsernameFromRequest(r)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		signOut(w, r)
+		return
+	}
+
+	ad.Role, err = db.GetUserRole(r.Context(), ad.Username)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get role: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == "POST" {
+		reason := inviteAction(r, &ad)
+		if reason != "" {
+			ad.Invites, _ = db.GetInvites(r.Context(), inviteOwner(ad))
+			Error(w, ad, reason)
+			return
+		}
+
+		ad.Success = true
+	}
+
+	ad.Invites, err = db.GetInvites(r.Context(), inviteOwner(ad))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get invites: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// inviteOwner returns whose invites the user manages, staff manage everyone's
+func inviteOwner(ad ActionData) string {
+	if db.HasPermission(ad.Role, db.PermUsers) {
+		return ""
+	}
+
+	return ad.Username
+}
+
+// inviteAction creates or deletes an invite, returning the reason it failed
+// if it did
+func inviteAction(r *http.Request, ad *ActionData) string {
+	admin := db.HasPermission(ad.Role, db.PermAccounts)
+
+	switch r.PostFormValue("action") {
+	case "create":
+		if registrationMode() != RegistrationInvite && !admin {
+			return "Registration doesn't use invites right now"
+		}
+
+		uses := userInviteUses
+		expires := time.Now().Add(userInviteHours * time.Hour)
+
+		if admin {
+			var err error
+			uses, err = strconv.Atoi(r.PostFormValue("uses"))
+			if err != nil || uses < 1 || uses > 1000 {
+				return "The number of uses is invalid"
+			}
+
+			expires = time.Time{}
+			if r.PostFormValue("hours") != "" {
+				hours, err := strconv.Atoi(r.PostFormValue("hours"))
+				if err != nil || hours <= 0 {
+					return "The selected expiry is invalid"
+				}
+
+				expires = time.Now().Add(time.Duration(hours) * time.Hour)
+			}
+		} else {
+			count, err := db.CountUsableInvites(r.Context(), ad.Username)
+			if err != nil {
+				return "An error occured while checking your invites"
+			}
+			if count >= maxUserInvites {
+				return fmt.Sprintf("You can only have %d unused invites at a time", maxUserInvites)
+			}
+		}
+
+		b := make([]byte, 9)
+		_, err := rand.Read(b)
+		if err != nil {
+			return "An error occured while generating the invite"
+		}
+
+		err = db.InsertInvite(r.Context(), ad.Username, base64.RawURLEncoding.EncodeToString(b), uses, expires)
+		if err != nil {
+			return "An error occured while saving the invite"
+		}
+
+		if admin {
+			audit(r, ad.Username, "invite", "", fmt.Sprintf("%d uses", uses))
+		}
+	case "delete":
+		err := db.DeleteInvite(r.Context(), inviteOwner(*ad), r.PostFormValue("code"))
+		if err != nil {
+			return "An error occured while deleting the invite"
+		}
+	default:
+		return "Unknown action"
+	}
+
+	return ""
+}
@@ -0,0 +1,77 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestInviteLimits(t *testing.T) {
+	dbtest.Init(t)
+	ctx := context.Background()
+
+	t.Setenv("REGISTRATION_MODE", RegistrationInvite)
+
+	tests := []struct {
+		role string
+		uses int // of an invite asking for 100
+	}{
+		{"", userInviteUses},
+		{db.RoleModerator, userInviteUses},
+		{db.RoleAdmin, 100},
+	}
+
+	for _, tt := range tests {
+		username := dbtest.Account(t, "correct horse")
+		if tt.role != "" {
+			err := db.SetUserRole(ctx, username, tt.role)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		form := url.Values{"action": {"create"}, "uses": {"100"}, "hours": {""}}
+
+		r := httptest.NewRequest("POST", "/invites", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		ad := ActionData{Username: username, Role: tt.role}
+
+		reason := inviteAction(r, &ad)
+		if reason != "" {
+			t.Errorf("%q: %s", tt.role, reason)
+			continue
+		}
+
+		invites, err := db.GetInvites(ctx, username)
+		if err != nil || len(invites) != 1 {
+			t.Fatalf("%q: got %d invites, %v", tt.role, len(invites), err)
+		}
+		if invites[0].MaxUses != tt.uses || invites[0].Expires.IsZero() != (tt.role == db.RoleAdmin) {
+			t.Errorf("%q: invite has %d uses and expires %s, want %d", tt.role, invites[0].MaxUses, invites[0].Expires, tt.uses)
+		}
+	}
+}
@@ -21,12 +21,13 @@ package frontend
 import (
 	"crypto/rand"
 	"database/sql"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/proxy"
+	"github.com/patapancakes/betablock/ratelimit"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -35,7 +36,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 	username, err := UsernameFromRequest(r)
 	if err != nil && err != http.ErrNoCookie {
-		http.Redirect(w, r, "/logout", http.StatusSeeOther)
+		signOut(w, r)
 		return
 	}
 
@@ -51,23 +52,33 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if os.Getenv("TS_SITE_KEY") != "" {
-		ok, err := verifyTurnstile(r)
-		if err != nil {
-			Error(w, ad, "Server error")
-			return
-		}
-		if !ok {
-			Error(w, ad, "Verification failed")
-			return
-		}
+	// second step for accounts with two-factor authentication, the first
+	// step already passed verification
+	if r.PostFormValue("token") != "" {
+		loginSecondFactor(w, r, ad)
+		return
 	}
 
 	// validate username and password
 	username = r.PostFormValue("username")
 
-	err = db.ValidatePassword(r.Context(), username, r.PostFormValue("password"))
+	wait, err := ratelimit.CheckLogin(r.Context(), proxy.ClientIP(r), username)
 	if err != nil {
+		Error(w, ad, "Server error")
+		return
+	}
+	if wait != 0 {
+		tooManyAttempts(w, ad, wait)
+		return
+	}
+
+	username, err = db.GetCanonicalUsername(r.Context(), username)
+	if err == nil {
+		err = db.ValidatePassword(r.Context(), username, r.PostFormValue("password"))
+	}
+	if err != nil {
+		ratelimit.LoginFailed(r.Context(), proxy.ClientIP(r), r.PostFormValue("username"))
+
 		var reason string
 		switch err {
 		case sql.ErrNoRows:
@@ -82,25 +93,71 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session := make([]byte, 16)
-	_, err = rand.Read(session)
+	// banned users get told why instead of a session
+	ban, err := db.GetActiveBan(r.Context(), username)
+	if err == nil {
+		ad.Header = "Banned"
+		ad.Page = "banned"
+		ad.Ban = &ban
+
+		err = t.Execute(w, ad)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	pending, err := db.IsAccountPending(r.Context(), username)
 	if err != nil {
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
+	if pending {
+		Error(w, ad, "Your account is awaiting approval by an admin")
+		return
+	}
 
-	err = db.InsertSession(r.Context(), username, session)
+	secret, _, err := db.GetTOTP(r.Context(), username)
 	if err != nil {
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
+	if secret != "" {
+		promptSecondFactor(w, r, ad, username, 0)
+		return
+	}
+
+	ratelimit.LoginSucceeded(r.Context(), username)
+
+	err = startSession(w, r, username)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, afterLogin(r, username), http.StatusSeeOther)
+}
+
+// startSession signs the user in to the website
+func startSession(w http.ResponseWriter, r *http.Request, username string) error {
+	session := make([]byte, 16)
+	_, err := rand.Read(session)
+	if err != nil {
+		return err
+	}
+
+	err = db.InsertSession(r.Context(), username, session, db.SessionWeb, proxy.ClientIP(r))
+	if err != nil {
+		return err
+	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    base64.StdEncoding.EncodeToString(session),
-		MaxAge:   60 * 60 * 24 * 7,
-		HttpOnly: true,
-	})
+	setCookie(w, "session", session, 60*60*24*7)
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
 }
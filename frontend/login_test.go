@@ -0,0 +1,136 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+// banCases bans a fresh account in each of the ways a ban can end up, and
+// reports whether it should still keep the user out
+var banCases = []struct {
+	name   string
+	ban    func(t *testing.T, username string)
+	banned bool
+}{
+	{"permanent", func(t *testing.T, username string) {
+		insertBan(t, username, time.Time{})
+	}, true},
+	{"suspended", func(t *testing.T, username string) {
+		insertBan(t, username, time.Now().Add(time.Hour))
+	}, true},
+	{"expired", func(t *testing.T, username string) {
+		insertBan(t, username, time.Now().Add(-time.Hour))
+	}, false},
+	{"lifted", func(t *testing.T, username string) {
+		insertBan(t, username, time.Time{})
+
+		err := db.LiftBans(context.Background(), username)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}, false},
+}
+
+func insertBan(t *testing.T, username string, expires time.Time) {
+	t.Helper()
+
+	err := db.InsertBan(context.Background(), username, "testing", "Admin", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoginBans(t *testing.T) {
+	dbtest.Init(t)
+
+	for _, tt := range banCases {
+		t.Run(tt.name, func(t *testing.T) {
+			username := dbtest.Account(t, "correct horse")
+			tt.ban(t, username)
+
+			form := url.Values{"username": {username}, "password": {"correct horse"}}
+
+			r := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			w := httptest.NewRecorder()
+			Login(w, r)
+
+			var session bool
+			for _, c := range w.Result().Cookies() {
+				if c.Name == "session" {
+					session = true
+				}
+			}
+
+			if session == tt.banned {
+				t.Errorf("login started a session %t, want %t", session, !tt.banned)
+			}
+			if strings.Contains(w.Body.String(), "testing") != tt.banned {
+				t.Errorf("ban reason shown %t, want %t", !tt.banned, tt.banned)
+			}
+		})
+	}
+}
+
+func TestLoginCase(t *testing.T) {
+	dbtest.Init(t)
+
+	username := dbtest.Account(t, "correct horse")
+
+	for _, name := range []string{username, strings.ToLower(username), strings.ToUpper(username)} {
+		form := url.Values{"username": {name}, "password": {"correct horse"}}
+
+		r := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		Login(w, r)
+
+		// the session belongs to the account under its own name
+		var owner string
+		for _, c := range w.Result().Cookies() {
+			if c.Name != "session" {
+				continue
+			}
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.AddCookie(c)
+
+			var err error
+			owner, err = UsernameFromRequest(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if owner != username {
+			t.Errorf("%s: logged in as %q, want %s", name, owner, username)
+		}
+	}
+}
@@ -18,14 +18,28 @@
 
 package frontend
 
-import "net/http"
+import (
+	"net/http"
 
+	"github.com/patapancakes/betablock/db"
+)
+
+// Logout signs the user out. It only takes posts from the website's own forms
+// so other sites can't sign people out with a link or image.
 func Logout(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+	signOut(w, r)
+}
+
+// signOut ends the request's session and sends the user to the front page,
+// for logging out and for requests with a session that's no longer valid
+func signOut(w http.ResponseWriter, r *http.Request) {
+	// end the session on the server too so the cookie can't be reused
+	session, err := sessionFromRequest(r)
+	if err == nil {
+		db.DeleteSession(r.Context(), session)
+	}
+
+	clearCookie(w, "session")
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
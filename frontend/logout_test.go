@@ -0,0 +1,82 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestLogout(t *testing.T) {
+	dbtest.Init(t)
+
+	username := dbtest.Account(t, "correct horse")
+
+	r := httptest.NewRequest("POST", "/logout", nil)
+	session := signIn(t, r, username)
+
+	w := httptest.NewRecorder()
+	Logout(w, r)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+		t.Errorf("got %d to %q, want a redirect to /", w.Code, w.Header().Get("Location"))
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].MaxAge >= 0 {
+		t.Errorf("session cookie wasn't cleared: %v", cookies)
+	}
+
+	_, err := db.GetUsernameFromSession(context.Background(), session)
+	if err == nil {
+		t.Error("session still works after logging out")
+	}
+}
+
+func TestRevokedSessionSignsOut(t *testing.T) {
+	dbtest.Init(t)
+
+	username := dbtest.Account(t, "correct horse")
+
+	r := httptest.NewRequest("GET", "/apppasswords", nil)
+	session := signIn(t, r, username)
+
+	err := db.DeleteSession(context.Background(), session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pages no longer send people to /logout, which only takes posts
+	w := httptest.NewRecorder()
+	AppPasswords(w, r)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+		t.Errorf("got %d to %q, want a redirect to /", w.Code, w.Header().Get("Location"))
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].MaxAge >= 0 {
+		t.Errorf("session cookie wasn't cleared: %v", cookies)
+	}
+}
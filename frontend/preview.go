@@ -0,0 +1,81 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/storage"
+)
+
+// Preview serves an uploaded image that isn't public yet to its owner and
+// to moderators
+func Preview(w http.ResponseWriter, r *http.Request) {
+	username, err := UsernameFromRequest(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	owner := r.URL.Query().Get("user")
+	kind := r.PathValue("kind")
+	hash := r.PathValue("hash")
+
+	if owner != username {
+		role, err := db.GetUserRole(r.Context(), username)
+		if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		if !db.HasPermission(role, db.PermModerate) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	ok, err := db.HasCosmetic(r.Context(), owner, kind, hash)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, info, err := storage.Open(r.Context(), cosmetic.ObjectKey(hash))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) || errors.Is(err, storage.ErrInvalidKey) {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private")
+	http.ServeContent(w, r, hash+".png", info.Modified, f)
+}
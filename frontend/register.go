@@ -19,13 +19,14 @@
 package frontend
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"regexp"
 	"strings"
 
 	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/mail"
 )
 
 var isValidUsername = regexp.MustCompile("^[A-Za-z0-9_]{3,16}$").MatchString
@@ -35,11 +36,12 @@ func Register(w http.ResponseWriter, r *http.Request) {
 
 	username, err := UsernameFromRequest(r)
 	if err != nil && err != http.ErrNoCookie {
-		http.Redirect(w, r, "/logout", http.StatusSeeOther)
+		signOut(w, r)
 		return
 	}
 
 	ad.Username = username
+	ad.Invite = r.FormValue("invite")
 
 	// show register page
 	if r.Method == "GET" {
@@ -52,16 +54,10 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if os.Getenv("TS_SITE_KEY") != "" {
-		ok, err := verifyTurnstile(r)
-		if err != nil {
-			Error(w, ad, "Server error")
-			return
-		}
-		if !ok {
-			Error(w, ad, "Verification failed")
-			return
-		}
+	mode := registrationMode()
+	if mode == RegistrationClosed {
+		Error(w, ad, "Registration is closed")
+		return
 	}
 
 	// try to register, show success page if ok
@@ -71,18 +67,61 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	password := strings.TrimSpace(r.PostFormValue("password"))
-	if len(password) > 72 {
-		Error(w, ad, "The password specified is too long")
+	password, reason := newPassword(r, "password", username)
+	if reason != "" {
+		Error(w, ad, reason)
 		return
 	}
 
-	err = db.InsertAccount(r.Context(), username, password)
+	email := strings.TrimSpace(r.PostFormValue("email"))
+	if email != "" && !isValidEmail(email) {
+		Error(w, ad, "The email address specified is invalid")
+		return
+	}
+
+	// the invite's use is taken along with creating the account, so two
+	// people can't share its last use
+	var invite string
+	if mode == RegistrationInvite {
+		invite = strings.TrimSpace(r.PostFormValue("invite"))
+		if invite == "" {
+			Error(w, ad, "The invite code is invalid, used up or expired")
+			return
+		}
+	}
+
+	err = db.RegisterAccount(r.Context(), username, password, invite, mode == RegistrationApproval)
 	if err != nil {
-		Error(w, ad, "An error occured while creating the account (username taken?)")
+		switch {
+		case errors.Is(err, db.ErrNameTaken):
+			Error(w, ad, "That username is taken")
+		case errors.Is(err, db.ErrNameReserved):
+			Error(w, ad, "That username was used by another account recently and is reserved")
+		case errors.Is(err, db.ErrInviteInvalid):
+			Error(w, ad, "The invite code is invalid, used up or expired")
+		default:
+			Error(w, ad, "An error occured while creating the account (username taken?)")
+		}
+
 		return
 	}
 
+	ad.Pending = mode == RegistrationApproval
+
+	if email != "" && mail.Enabled() {
+		err = db.SetEmail(r.Context(), username, email)
+		if err != nil {
+			Error(w, ad, "The account was created but the email address couldn't be saved")
+			return
+		}
+
+		err = sendVerification(r.Context(), username, email)
+		if err != nil {
+			Error(w, ad, "The account was created but the verification email couldn't be sent")
+			return
+		}
+	}
+
 	ad.Success = true
 	ad.Username = username
 
@@ -0,0 +1,75 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+func TestRegisterCase(t *testing.T) {
+	dbtest.Init(t)
+	t.Setenv("REGISTRATION_MODE", RegistrationOpen)
+
+	username := dbtest.Account(t, "correct horse")
+
+	tests := []struct {
+		name  string
+		taken bool
+	}{
+		{username, true},
+		{strings.ToLower(username), true},
+		{strings.ToUpper(username), true},
+		{dbtest.Username(t), false},
+	}
+
+	for _, tt := range tests {
+		form := url.Values{"username": {tt.name}, "password": {"correct horse battery"}, "confirm": {"correct horse battery"}}
+
+		r := httptest.NewRequest("POST", "/register", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		Register(w, r)
+
+		if strings.Contains(w.Body.String(), "That username is taken") != tt.taken {
+			t.Errorf("%s: taken %t, want %t", tt.name, !tt.taken, tt.taken)
+		}
+
+		canonical, err := db.GetCanonicalUsername(context.Background(), tt.name)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+
+		want := tt.name
+		if tt.taken {
+			want = username
+		}
+		if canonical != want {
+			t.Errorf("%s: account is named %s, want %s", tt.name, canonical, want)
+		}
+	}
+}
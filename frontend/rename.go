@@ -0,0 +1,139 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+)
+
+func Rename(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Change Username", Page: "rename"}
+
+	var err error
+	ad.Username, err = UsernameFromRequest(r)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		signOut(w, r)
+		return
+	}
+
+	if !requireReauth(w, r, "/rename") {
+		return
+	}
+
+	if r.Method == "POST" {
+		reason := renameAction(r, &ad)
+		if reason != "" {
+			loadNames(r, &ad)
+			Error(w, ad, reason)
+			return
+		}
+
+		ad.Success = true
+	}
+
+	err = loadNames(r, &ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get name history: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// loadNames fills in the user's previous names and when they can rename
+// again
+func loadNames(r *http.Request, ad *ActionData) error {
+	id, err := db.GetAccountID(r.Context(), ad.Username)
+	if err != nil {
+		return err
+	}
+
+	ad.Names, err = db.GetNameHistory(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	ad.RenameAvailable = time.Time{}
+	if len(ad.Names) != 0 {
+		available := ad.Names[len(ad.Names)-1].Changed.Add(db.RenameInterval)
+		if time.Now().Before(available) {
+			ad.RenameAvailable = available
+		}
+	}
+
+	return nil
+}
+
+// renameAction changes the user's username and moves their cosmetics to the
+// new one, returning the reason it failed if it did
+func renameAction(r *http.Request, ad *ActionData) string {
+	username := strings.TrimSpace(r.PostFormValue("username"))
+	if !isValidUsername(username) {
+		return "The username specified is invalid"
+	}
+
+	if username == ad.Username {
+		return "That's already your username"
+	}
+
+	err := db.RenameAccount(r.Context(), ad.Username, username)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNameTaken):
+			return "That username is taken"
+		case errors.Is(err, db.ErrNameReserved):
+			return "That username was used by another account recently and is reserved"
+		case errors.Is(err, db.ErrRenameTooSoon):
+			return "You've changed your username too recently"
+		default:
+			return "An error occured while changing your username"
+		}
+	}
+
+	old := ad.Username
+	ad.Username = username
+
+	// the old name is reserved, so nobody else can take these keys meanwhile
+	for _, kind := range []cosmetic.Kind{cosmetic.Skin, cosmetic.Cape} {
+		err = cosmetic.Move(r.Context(), kind, old, username)
+		if err != nil {
+			log.Printf("failed to move %s from %s to %s: %s", kind, old, username, err)
+			return fmt.Sprintf("Your username was changed but your %s couldn't be moved, please upload it again", kind)
+		}
+	}
+
+	return ""
+}
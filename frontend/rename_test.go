@@ -0,0 +1,143 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+	"github.com/patapancakes/betablock/storage"
+)
+
+// caseInsensitive is a store that ignores the case of keys, like local
+// storage on macOS or Windows
+type caseInsensitive struct {
+	storage.Store
+}
+
+func (s caseInsensitive) Open(ctx context.Context, key string) (io.ReadSeekCloser, storage.Info, error) {
+	return s.Store.Open(ctx, strings.ToLower(key))
+}
+
+func (s caseInsensitive) Stat(ctx context.Context, key string) (storage.Info, error) {
+	return s.Store.Stat(ctx, strings.ToLower(key))
+}
+
+func (s caseInsensitive) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	return s.Store.Put(ctx, strings.ToLower(key), r, size)
+}
+
+func (s caseInsensitive) Delete(ctx context.Context, key string) error {
+	return s.Store.Delete(ctx, strings.ToLower(key))
+}
+
+func renameRequest(t *testing.T, ad *ActionData, username string) string {
+	t.Helper()
+
+	form := url.Values{"username": {username}}
+
+	r := httptest.NewRequest("POST", "/rename", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return renameAction(r, ad)
+}
+
+func TestRenameActionCase(t *testing.T) {
+	dbtest.Init(t)
+
+	for _, store := range []struct {
+		name string
+		wrap func(storage.Store) storage.Store
+	}{
+		{"case-sensitive", func(s storage.Store) storage.Store { return s }},
+		{"case-insensitive", func(s storage.Store) storage.Store { return caseInsensitive{s} }},
+	} {
+		t.Run(store.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			local, err := storage.NewLocal(t.TempDir(), storage.SymlinksRoot)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			storage.Init(store.wrap(local))
+
+			username := dbtest.Account(t, "correct horse")
+			renamed := strings.ToUpper(username)
+
+			skin := color.NRGBA{255, 0, 0, 255}
+
+			err = cosmetic.Publish(ctx, cosmetic.Skin, username, solidImage(64, 32, skin))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ad := ActionData{Username: username}
+
+			reason := renameRequest(t, &ad, username)
+			if reason != "That's already your username" {
+				t.Errorf("renaming to the same name: got %q", reason)
+			}
+
+			reason = renameRequest(t, &ad, renamed)
+			if reason != "" {
+				t.Fatalf("renaming to %s: got %q", renamed, reason)
+			}
+
+			canonical, err := db.GetCanonicalUsername(ctx, username)
+			if err != nil || canonical != renamed || ad.Username != canonical {
+				t.Errorf("account is named %q, %v, page shows %s, want %s", canonical, err, ad.Username, renamed)
+			}
+
+			// the skin is served and rendered under the new name
+			c, ok := publishedColor(t, cosmetic.Skin, renamed)
+			if !ok || c != skin {
+				t.Errorf("skin under the new name is %v, %t, want %v", c, ok, skin)
+			}
+
+			_, _, err = cosmetic.GetRender(ctx, cosmetic.RenderFace, renamed, 8)
+			if err != nil {
+				t.Errorf("failed to render the skin under the new name: %s", err)
+			}
+
+			// and nothing is left under the old one, or the temporary one
+			for _, name := range []string{username, username + ".moving"} {
+				if strings.EqualFold(name, renamed) && store.name == "case-insensitive" {
+					continue
+				}
+
+				for _, key := range []string{cosmetic.Key(cosmetic.Skin, name), cosmetic.OriginalKey(cosmetic.Skin, name)} {
+					_, err = storage.Stat(ctx, key)
+					if !errors.Is(err, storage.ErrNotExist) {
+						t.Errorf("%s is still stored: %v", key, err)
+					}
+				}
+			}
+		})
+	}
+}
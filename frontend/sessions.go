@@ -0,0 +1,102 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/patapancakes/betablock/db"
+)
+
+func Sessions(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Sessions", Page: "sessions"}
+
+	var err error
+	ad.Username, err = UsernameFromRequest(r)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		signOut(w, r)
+		return
+	}
+
+	// UsernameFromRequest already checked the cookie
+	session, _ := sessionFromRequest(r)
+
+	ad.CurrentSession, err = db.GetSessionID(r.Context(), session)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get session: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == "POST" {
+		if r.PostFormValue("action") == "all" {
+			err = db.DeleteUserSessions(r.Context(), ad.Username)
+			if err != nil {
+				Error(w, ad, "An error occured while signing out your sessions")
+				return
+			}
+
+			err = db.DeleteUserTicket(r.Context(), ad.Username)
+			if err != nil {
+				Error(w, ad, "An error occured while signing out your sessions")
+				return
+			}
+
+			signOut(w, r)
+			return
+		}
+
+		id, err := strconv.ParseInt(r.PostFormValue("id"), 10, 64)
+		if err != nil {
+			Error(w, ad, "The selected session is invalid")
+			return
+		}
+
+		err = db.DeleteUserSession(r.Context(), ad.Username, id)
+		if err != nil {
+			Error(w, ad, "An error occured while signing out the session")
+			return
+		}
+
+		if id == ad.CurrentSession {
+			signOut(w, r)
+			return
+		}
+
+		ad.Success = true
+	}
+
+	ad.Sessions, err = db.GetUserSessions(r.Context(), ad.Username)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get sessions: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
@@ -19,18 +19,32 @@
 package frontend
 
 import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"image/png"
+	"html/template"
+	"image"
 	"net/http"
 	"os"
-	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/storage"
 )
 
 func SetCosmetic(w http.ResponseWriter, r *http.Request) {
-	cape := r.URL.Path == "/setcape"
+	kind := cosmetic.Skin
+	if r.URL.Path == "/setcape" {
+		kind = cosmetic.Cape
+	}
 
 	ad := ActionData{Header: "Set Skin", Page: "setskin"}
-	if cape {
+	if kind == cosmetic.Cape {
 		ad.Header = "Set Cape"
 		ad.Page = "setcape"
 	}
@@ -42,12 +56,32 @@ func SetCosmetic(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		http.Redirect(w, r, "/logout", http.StatusSeeOther)
+		signOut(w, r)
 		return
 	}
 
 	ad.Username = username
 
+	ad.History, err = db.GetCosmeticHistory(r.Context(), username, string(kind))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get cosmetic history: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	if kind == cosmetic.Cape {
+		ad.Capes, err = db.GetUserCapes(r.Context(), username)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to get granted capes: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		ad.CustomCape, err = db.HasEntitlement(r.Context(), username, db.EntitlementCustomCape)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to get entitlements: %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if r.Method == "GET" {
 		err := t.Execute(w, ad)
 		if err != nil {
@@ -58,81 +92,249 @@ func SetCosmetic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if os.Getenv("TS_SITE_KEY") != "" {
-		ok, err := verifyTurnstile(r)
+	// parse form data
+	err = r.ParseMultipartForm(maxUploadSize)
+	if err != nil {
+		Error(w, ad, "An error occured while parsing your request")
+		return
+	}
+
+	if kind == cosmetic.Cape {
+		// pick a granted cape
+		if id := r.PostFormValue("cape"); id != "" {
+			reason := selectCape(r, ad, id)
+			if reason != "" {
+				Error(w, ad, reason)
+				return
+			}
+
+			ad.Success = true
+
+			err = t.Execute(w, ad)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+				return
+			}
+
+			return
+		}
+
+		if !ad.CustomCape {
+			Error(w, ad, "You aren't allowed to upload custom capes")
+			return
+		}
+	}
+
+	var image image.Image
+
+	// restore from history or decode and validate upload
+	status := db.CosmeticApproved
+
+	hash := r.PostFormValue("restore")
+	if hash != "" {
+		current, err := db.GetCosmeticStatus(r.Context(), username, string(kind), hash)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				Error(w, ad, "The selected image isn't in your history")
+				return
+			}
+
+			Error(w, ad, "An error occured while reading your history")
+			return
+		}
+		if current != db.CosmeticApproved {
+			Error(w, ad, "The selected image hasn't been approved")
+			return
+		}
+
+		image, err = cosmetic.Load(r.Context(), hash)
 		if err != nil {
-			Error(w, ad, "Server error")
+			Error(w, ad, "An error occured while reading the image")
 			return
 		}
-		if !ok {
-			Error(w, ad, "Verification failed")
+	} else {
+		var reason string
+		image, reason = readUpload(r, kind, &ad)
+		if reason != "" {
+			Error(w, ad, reason)
 			return
 		}
+
+		hash, err = cosmetic.Store(r.Context(), image)
+		if err != nil {
+			Error(w, ad, "An error occured while storing the image")
+			return
+		}
+
+		// identical images that were approved before don't need another review
+		if moderated() {
+			approved, err := db.IsCosmeticApproved(r.Context(), hash)
+			if err != nil {
+				Error(w, ad, "An error occured while checking the image")
+				return
+			}
+			if !approved {
+				status = db.CosmeticPending
+			}
+		}
 	}
 
-	// parse form data
-	err = r.ParseMultipartForm(maxUploadSize)
+	err = db.InsertCosmetic(r.Context(), username, string(kind), hash, status)
 	if err != nil {
-		Error(w, ad, "An error occured while parsing your request")
+		Error(w, ad, "An error occured while updating your history")
 		return
 	}
 
-	// decode and validate image
-	f, fh, err := r.FormFile("image")
-	if err != nil {
-		Error(w, ad, "An error occured while reading the image")
-		return
+	if status == db.CosmeticApproved {
+		err = publishCosmetic(r.Context(), kind, username, image)
+		if err != nil {
+			Error(w, ad, "An error occured while storing the image")
+			return
+		}
 	}
 
-	defer f.Close()
+	ad.History, _ = db.GetCosmeticHistory(r.Context(), username, string(kind))
+	ad.Success = true
+	ad.Pending = status == db.CosmeticPending
 
-	if fh.Size > maxUploadSize {
-		Error(w, ad, "The image is too large")
-		return
-	}
-	if fh.Header.Get("Content-Type") != "image/png" {
-		Error(w, ad, "The image is the wrong type")
+	// write page
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
 		return
 	}
+}
+
+// moderated reports whether uploads need to be approved before they're public
+func moderated() bool {
+	return os.Getenv("MODERATION") != ""
+}
 
-	image, err := png.Decode(f)
+// publishCosmetic makes image the user's current cosmetic
+func publishCosmetic(ctx context.Context, kind cosmetic.Kind, username string, image image.Image) error {
+	err := cosmetic.Publish(ctx, kind, username, image)
 	if err != nil {
-		Error(w, ad, "The image couldn't be decoded")
-		return
+		return err
 	}
 
-	dim := image.Bounds()
-	if dim.Dx() > 64 || dim.Dy() > 64 {
-		Error(w, ad, "The image dimensions are too large")
-		return
+	// uploaded capes replace granted ones
+	if kind == cosmetic.Cape {
+		return db.ClearSelectedCape(ctx, username)
+	}
+
+	return nil
+}
+
+// selectCape switches the user to one of their granted capes, or removes
+// their cape if id is "none", returning the reason it failed if it did
+func selectCape(r *http.Request, ad ActionData, id string) string {
+	if id == "none" {
+		err := cosmetic.Remove(r.Context(), cosmetic.Cape, ad.Username)
+		if err != nil {
+			return "An error occured while removing your cape"
+		}
+
+		err = db.ClearSelectedCape(r.Context(), ad.Username)
+		if err != nil {
+			return "An error occured while updating your cape"
+		}
+
+		return ""
 	}
 
-	dir := "skins"
-	if cape {
-		dir = "capes"
+	i := slices.IndexFunc(ad.Capes, func(c db.Cape) bool { return strconv.Itoa(c.ID) == id })
+	if i == -1 {
+		return "The selected cape hasn't been granted to you"
 	}
 
-	// open dest file for writing
-	sf, err := os.OpenFile(filepath.Join("public", dir, username+".png"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	image, err := cosmetic.Load(r.Context(), ad.Capes[i].Hash)
 	if err != nil {
-		Error(w, ad, "An error occured while creating the image")
-		return
+		return "An error occured while reading the cape"
 	}
 
-	defer sf.Close()
+	err = cosmetic.Publish(r.Context(), cosmetic.Cape, ad.Username, image)
+	if err != nil {
+		return "An error occured while storing the cape"
+	}
 
-	err = png.Encode(sf, image)
+	err = db.SetSelectedCape(r.Context(), ad.Username, ad.Capes[i].ID)
 	if err != nil {
-		Error(w, ad, "An error occured while encoding the image")
-		return
+		return "An error occured while updating your cape"
 	}
 
-	ad.Success = true
+	return ""
+}
 
-	// write page
-	err = t.Execute(w, ad)
+// readUpload decodes and validates the uploaded or imported image, returning
+// the reason it was rejected if it isn't usable
+func readUpload(r *http.Request, kind cosmetic.Kind, ad *ActionData) (image.Image, string) {
+	var image image.Image
+
+	switch {
+	case r.PostFormValue("importuser") != "": // copy another player's
+		username, err := db.GetCanonicalUsername(r.Context(), strings.TrimSpace(r.PostFormValue("importuser")))
+		if err != nil {
+			return nil, "The specified user doesn't exist"
+		}
+
+		image, err = cosmetic.LoadPublished(r.Context(), kind, username)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotExist) {
+				return nil, fmt.Sprintf("%s doesn't have a %s", username, kind)
+			}
+
+			return nil, "An error occured while reading the image"
+		}
+	case r.PostFormValue("importurl") != "": // download from elsewhere
+		var err error
+		image, err = cosmetic.Fetch(r.Context(), strings.TrimSpace(r.PostFormValue("importurl")))
+		if err != nil {
+			switch {
+			case errors.Is(err, cosmetic.ErrForbiddenURL):
+				return nil, "Only http and https URLs can be imported"
+			case errors.Is(err, cosmetic.ErrForbiddenAddress):
+				return nil, "The URL points to an address that can't be imported from, only public addresses on the default ports are allowed"
+			case errors.Is(err, cosmetic.ErrNotPNG):
+				return nil, "The URL isn't a PNG image"
+			case errors.Is(err, cosmetic.ErrTooLarge):
+				return nil, "The image is too large"
+			default:
+				return nil, "The image couldn't be downloaded or decoded"
+			}
+		}
+	default:
+		f, fh, err := r.FormFile("image")
+		if err != nil {
+			return nil, "An error occured while reading the image"
+		}
+
+		defer f.Close()
+
+		if fh.Size > maxUploadSize {
+			return nil, "The image is too large"
+		}
+		if fh.Header.Get("Content-Type") != "image/png" {
+			return nil, "The image is the wrong type"
+		}
+
+		image, err = cosmetic.Decode(f)
+		if err != nil {
+			return nil, "The image couldn't be decoded"
+		}
+	}
+
+	err := cosmetic.Validate(kind, image)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
-		return
+		var verr *cosmetic.ValidationError
+		if errors.As(err, &verr) && len(verr.Regions) != 0 {
+			preview, err := verr.Preview()
+			if err == nil {
+				ad.Preview = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(preview))
+			}
+		}
+
+		return nil, err.Error()
 	}
+
+	return image, ""
 }
@@ -0,0 +1,135 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/patapancakes/betablock/cosmetic"
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/db/dbtest"
+)
+
+// solidImage returns a w by h image filled with c
+func solidImage(w int, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+
+	return img
+}
+
+// storeCosmetic stores img and records it in username's history
+func storeCosmetic(t *testing.T, username string, kind cosmetic.Kind, img image.Image, status string) string {
+	t.Helper()
+
+	hash, err := cosmetic.Store(context.Background(), img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.InsertCosmetic(context.Background(), username, string(kind), hash, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+// publishedColor returns the colour of the first pixel of username's current
+// cosmetic, or false if they don't have one
+func publishedColor(t *testing.T, kind cosmetic.Kind, username string) (color.NRGBA, bool) {
+	t.Helper()
+
+	img, err := cosmetic.LoadPublished(context.Background(), kind, username)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+
+	return color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA), true
+}
+
+func TestRestoreCosmetic(t *testing.T) {
+	dbtest.Init(t)
+	initStorage(t)
+
+	ctx := context.Background()
+
+	username := dbtest.Account(t, "correct horse")
+	other := dbtest.Account(t, "correct horse")
+
+	red := color.NRGBA{255, 0, 0, 255}
+	green := color.NRGBA{0, 255, 0, 255}
+
+	old := storeCosmetic(t, username, cosmetic.Skin, solidImage(64, 32, red), db.CosmeticApproved)
+	rejected := storeCosmetic(t, username, cosmetic.Skin, solidImage(64, 32, color.NRGBA{0, 0, 255, 255}), db.CosmeticRejected)
+	theirs := storeCosmetic(t, other, cosmetic.Skin, solidImage(64, 64, color.NRGBA{1, 2, 3, 255}), db.CosmeticApproved)
+	storeCosmetic(t, username, cosmetic.Skin, solidImage(64, 32, green), db.CosmeticApproved)
+
+	err := cosmetic.Publish(ctx, cosmetic.Skin, username, solidImage(64, 32, green))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		hash   string
+		reason string
+		want   color.NRGBA // published afterwards
+	}{
+		{"rejected", rejected, "been approved", green},
+		{"someone else's", theirs, "in your history", green},
+		{"unknown", strings.Repeat("0", 64), "in your history", green},
+		{"old", old, "", red},
+	}
+
+	for _, tt := range tests {
+		r := multipartRequest(t, "/setskin", map[string]string{"restore": tt.hash})
+		signIn(t, r, username)
+
+		w := httptest.NewRecorder()
+		SetCosmetic(w, r)
+
+		if tt.reason != "" && !strings.Contains(w.Body.String(), tt.reason) {
+			t.Errorf("%s: response doesn't mention %q", tt.name, tt.reason)
+		}
+
+		published, ok := publishedColor(t, cosmetic.Skin, username)
+		if !ok || published != tt.want {
+			t.Errorf("%s: published skin is %v, %t, want %v", tt.name, published, ok, tt.want)
+		}
+	}
+
+	// restoring doesn't make a new object or history entry
+	history, err := db.GetCosmeticHistory(ctx, username, string(cosmetic.Skin))
+	if err != nil || len(history) != 3 {
+		t.Errorf("history has %d entries after restoring, %v, want 3", len(history), err)
+	}
+
+	status, err := db.GetCosmeticStatus(ctx, username, string(cosmetic.Skin), old)
+	if err != nil || status != db.CosmeticApproved {
+		t.Errorf("restored entry is %q, %v, want approved", status, err)
+	}
+}
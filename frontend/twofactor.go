@@ -0,0 +1,431 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patapancakes/betablock/db"
+	"github.com/patapancakes/betablock/proxy"
+	"github.com/patapancakes/betablock/qr"
+	"github.com/patapancakes/betablock/ratelimit"
+	"github.com/patapancakes/betablock/totp"
+)
+
+const (
+	loginTokenLifetime = time.Minute * 5
+	maxLoginAttempts   = 5
+
+	reauthLifetime = time.Minute * 10
+
+	recoveryCodeCount = 10
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// promptSecondFactor shows the code form for the second step of a login
+func promptSecondFactor(w http.ResponseWriter, r *http.Request, ad ActionData, username string, attempts int) {
+	token, encoded, err := newToken()
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = db.InsertToken(r.Context(), username, db.TokenLogin, token, strconv.Itoa(attempts), loginTokenLifetime)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	ad.Header = "Two-Factor Authentication"
+	ad.Page = "twofactorlogin"
+	ad.Token = encoded
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// loginSecondFactor finishes a login once the user enters a code
+func loginSecondFactor(w http.ResponseWriter, r *http.Request, ad ActionData) {
+	token, err := hex.DecodeString(r.PostFormValue("token"))
+	if err != nil {
+		Error(w, ad, "The login has expired, please try again")
+		return
+	}
+
+	username, data, err := db.ConsumeToken(r.Context(), db.TokenLogin, token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			Error(w, ad, "The login has expired, please try again")
+			return
+		}
+
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	ok, err := checkSecondFactor(r.Context(), username, r.PostFormValue("code"))
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		ratelimit.LoginFailed(r.Context(), proxy.ClientIP(r), username)
+
+		attempts, _ := strconv.Atoi(data)
+		if attempts+1 >= maxLoginAttempts {
+			Error(w, ad, "Too many incorrect codes, please log in again")
+			return
+		}
+
+		ad.Error = "The code is incorrect"
+		promptSecondFactor(w, r, ad, username, attempts+1)
+		return
+	}
+
+	ratelimit.LoginSucceeded(r.Context(), username)
+
+	err = startSession(w, r, username)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, afterLogin(r, username), http.StatusSeeOther)
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code, consuming whichever it was
+func checkSecondFactor(ctx context.Context, username string, code string) (bool, error) {
+	secret, last, err := db.GetTOTP(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	if secret == "" {
+		return false, nil
+	}
+
+	code = strings.TrimSpace(code)
+
+	step, ok := totp.Validate(secret, code, time.Now(), last)
+	if ok {
+		return db.UseTOTPStep(ctx, username, step)
+	}
+
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if code == "" {
+		return false, nil
+	}
+
+	return db.UseRecoveryCode(ctx, username, code)
+}
+
+// newRecoveryCodes returns a set of random single-use codes, formatted in
+// two groups for readability
+func newRecoveryCodes() ([]string, []string, error) {
+	var codes, formatted []string
+	for range recoveryCodeCount {
+		b := make([]byte, 5)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := recoveryEncoding.EncodeToString(b)
+
+		codes = append(codes, code)
+		formatted = append(formatted, code[:4]+"-"+code[4:])
+	}
+
+	return codes, formatted, nil
+}
+
+// requireReauth redirects the user to confirm their identity and then return
+// to next unless they did so recently in this session, returning false if it
+// redirected
+func requireReauth(w http.ResponseWriter, r *http.Request, next string) bool {
+	session, err := sessionFromRequest(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return false
+	}
+
+	reauth, err := db.GetSessionReauth(r.Context(), session)
+	if err != nil {
+		signOut(w, r)
+		return false
+	}
+	if time.Since(reauth) < reauthLifetime {
+		return true
+	}
+
+	http.Redirect(w, r, "/reauth?next="+url.QueryEscape(next), http.StatusSeeOther)
+	return false
+}
+
+// isLocalPath reports whether next is safe to redirect to after a reauth
+func isLocalPath(next string) bool {
+	return strings.HasPrefix(next, "/") && !strings.HasPrefix(next, "//") && !strings.HasPrefix(next, "/\\")
+}
+
+func Reauth(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Confirm Your Identity", Page: "reauth"}
+
+	var err error
+	ad.Username, err = UsernameFromRequest(r)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		signOut(w, r)
+		return
+	}
+
+	ad.Next = r.FormValue("next")
+	if !isLocalPath(ad.Next) {
+		ad.Next = "/"
+	}
+
+	secret, _, err := db.GetTOTP(r.Context(), ad.Username)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get two-factor status: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	ad.TwoFactor = secret != ""
+
+	if r.Method == "GET" {
+		err := t.Execute(w, ad)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		return
+	}
+
+	// a stolen session shouldn't allow guessing the password
+	wait, err := ratelimit.CheckLogin(r.Context(), proxy.ClientIP(r), ad.Username)
+	if err != nil {
+		Error(w, ad, "Server error")
+		return
+	}
+	if wait != 0 {
+		tooManyAttempts(w, ad, wait)
+		return
+	}
+
+	err = db.ValidatePassword(r.Context(), ad.Username, r.PostFormValue("password"))
+	if err != nil {
+		ratelimit.LoginFailed(r.Context(), proxy.ClientIP(r), ad.Username)
+		Error(w, ad, "The password is incorrect")
+		return
+	}
+
+	if ad.TwoFactor {
+		ok, err := checkSecondFactor(r.Context(), ad.Username, r.PostFormValue("code"))
+		if err != nil {
+			Error(w, ad, "An error occured while checking the code")
+			return
+		}
+		if !ok {
+			ratelimit.LoginFailed(r.Context(), proxy.ClientIP(r), ad.Username)
+			Error(w, ad, "The code is incorrect")
+			return
+		}
+	}
+
+	ratelimit.LoginSucceeded(r.Context(), ad.Username)
+
+	session, _ := sessionFromRequest(r)
+
+	err = db.SetSessionReauth(r.Context(), session)
+	if err != nil {
+		Error(w, ad, "An error occured while confirming your identity")
+		return
+	}
+
+	http.Redirect(w, r, ad.Next, http.StatusSeeOther)
+}
+
+func TwoFactor(w http.ResponseWriter, r *http.Request) {
+	ad := ActionData{Header: "Two-Factor Authentication", Page: "twofactor"}
+
+	var err error
+	ad.Username, err = UsernameFromRequest(r)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		signOut(w, r)
+		return
+	}
+
+	if !requireReauth(w, r, "/twofactor") {
+		return
+	}
+
+	if r.Method == "POST" {
+		reason := twoFactorAction(r, &ad)
+		if reason != "" {
+			loadTwoFactor(r, &ad)
+			Error(w, ad, reason)
+			return
+		}
+
+		ad.Success = true
+	}
+
+	err = loadTwoFactor(r, &ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get two-factor status: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = t.Execute(w, ad)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// loadTwoFactor fills in the user's two-factor status, or a new secret to
+// enroll with if it's off
+func loadTwoFactor(r *http.Request, ad *ActionData) error {
+	secret, _, err := db.GetTOTP(r.Context(), ad.Username)
+	if err != nil {
+		return err
+	}
+
+	ad.TwoFactor = secret != ""
+
+	if ad.TwoFactor {
+		ad.RecoveryCount, err = db.CountRecoveryCodes(r.Context(), ad.Username)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if ad.Secret == "" {
+		ad.Secret, err = totp.NewSecret()
+		if err != nil {
+			return err
+		}
+	}
+
+	code, err := qr.Encode([]byte(totp.URI("Betablock", ad.Username, ad.Secret)))
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	err = png.Encode(&b, code.Image(4))
+	if err != nil {
+		return err
+	}
+
+	ad.QR = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(b.Bytes()))
+
+	return nil
+}
+
+// twoFactorAction performs the requested two-factor management action,
+// returning the reason it failed if it did
+func twoFactorAction(r *http.Request, ad *ActionData) string {
+	secret, _, err := db.GetTOTP(r.Context(), ad.Username)
+	if err != nil {
+		return "An error occured while reading your account"
+	}
+
+	action := r.PostFormValue("action")
+	if action == "enable" && secret != "" {
+		return "Two-factor authentication is already on"
+	}
+	if action != "enable" && secret == "" {
+		return "Two-factor authentication is off"
+	}
+
+	switch action {
+	case "enable":
+		pending := r.PostFormValue("secret")
+		if len(pending) != 32 {
+			return "The setup has expired, please try again"
+		}
+
+		// keep showing the same secret if the code is wrong
+		ad.Secret = pending
+
+		step, ok := totp.Validate(pending, strings.TrimSpace(r.PostFormValue("code")), time.Now(), 0)
+		if !ok {
+			return "The code is incorrect, check your device's clock and try again"
+		}
+
+		err = db.EnableTOTP(r.Context(), ad.Username, pending, step)
+		if err != nil {
+			return "An error occured while enabling two-factor authentication"
+		}
+
+		fallthrough
+	case "recovery":
+		codes, formatted, err := newRecoveryCodes()
+		if err != nil {
+			return "An error occured while generating recovery codes"
+		}
+
+		err = db.SetRecoveryCodes(r.Context(), ad.Username, codes)
+		if err != nil {
+			return "An error occured while saving recovery codes"
+		}
+
+		ad.RecoveryCodes = formatted
+	case "disable":
+		err = db.DisableTOTP(r.Context(), ad.Username)
+		if err != nil {
+			return "An error occured while disabling two-factor authentication"
+		}
+	default:
+		return "Unknown action"
+	}
+
+	return ""
+}
@@ -1,6 +1,6 @@
 module github.com/patapancakes/betablock
 
-go 1.24.2
+go 1.25.0
 
 require (
 	github.com/go-sql-driver/mysql v1.9.3
@@ -0,0 +1,57 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mail
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotConfigured = errors.New("no mailer is configured")
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var mailer Mailer
+
+func Init(m Mailer) {
+	mailer = m
+}
+
+// Enabled reports whether a mailer is configured
+func Enabled() bool {
+	return mailer != nil
+}
+
+func Send(ctx context.Context, msg Message) error {
+	if mailer == nil {
+		return ErrNotConfigured
+	}
+
+	return mailer.Send(ctx, msg)
+}
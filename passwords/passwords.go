@@ -0,0 +1,77 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package passwords
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// bcrypt ignores anything past this many bytes
+const MaxLength = 72
+
+var (
+	ErrTooShort = errors.New("password is too short")
+	ErrTooLong  = errors.New("password is too long")
+	ErrCommon   = errors.New("password is too common")
+	ErrUsername = errors.New("password is the username")
+)
+
+// MinLength is the shortest password allowed
+var MinLength = 8
+
+//go:embed common.txt
+var commonList string
+
+var common = make(map[string]struct{})
+
+func init() {
+	for _, line := range strings.Split(commonList, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		common[strings.ToLower(line)] = struct{}{}
+	}
+}
+
+// Check returns why password can't be used by username, or nil if it can
+func Check(username string, password string) error {
+	if utf8.RuneCountInString(password) < MinLength {
+		return ErrTooShort
+	}
+
+	if len(password) > MaxLength {
+		return ErrTooLong
+	}
+
+	if strings.EqualFold(password, username) {
+		return ErrUsername
+	}
+
+	_, ok := common[strings.ToLower(password)]
+	if ok {
+		return ErrCommon
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	for _, c := range []struct {
+		username string
+		password string
+		want     error
+	}{
+		{"Notch", "battery staple", nil},
+		{"Notch", "short", ErrTooShort},
+		{"Notch", "sevenéé", ErrTooShort}, // 9 bytes but 7 characters
+		{"Notch", "eightééé", nil},        // counted in characters
+		{"Notch", strings.Repeat("a", 72), nil},
+		{"Notch", strings.Repeat("a", 73), ErrTooLong},
+		{"Notch", strings.Repeat("é", 37), ErrTooLong}, // 37 characters but 74 bytes
+		{"Notch", "password", ErrCommon},
+		{"Notch", "PassWord", ErrCommon},
+		{"Notch1234", "notch1234", ErrUsername},
+		{"Notch1234", "NOTCH1234", ErrUsername},
+		{"Notch1234", "Notch12345", nil},
+	} {
+		err := Check(c.username, c.password)
+		if !errors.Is(err, c.want) {
+			t.Errorf("Check(%q, %q) = %v, want %v", c.username, c.password, err, c.want)
+		}
+	}
+}
+
+func TestCommonList(t *testing.T) {
+	if len(common) < 100 {
+		t.Fatalf("only %d common passwords were loaded", len(common))
+	}
+
+	for password := range common {
+		if password != strings.ToLower(strings.TrimSpace(password)) || strings.HasPrefix(password, "#") {
+			t.Errorf("common password %q wasn't normalized", password)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// trusted holds the reverse proxies whose X-Forwarded-For headers are
+// believed. Requests from anywhere else use the connecting address.
+var trusted []netip.Prefix
+
+// Init sets the trusted proxies from a comma separated list of addresses
+// and CIDR ranges
+func Init(list string) error {
+	trusted = nil
+
+	for _, s := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+			}
+
+			trusted = append(trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+
+		trusted = append(trusted, prefix.Masked())
+	}
+
+	return nil
+}
+
+func isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP returns the address of the client that made the request. If it
+// came through trusted proxies, X-Forwarded-For is followed back to the
+// first address that isn't one. Connections over a unix socket can only come
+// from the reverse proxy in front of us, so they're always trusted.
+func ClientIP(r *http.Request) string {
+	var addr netip.Addr
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err == nil {
+		addr, err = netip.ParseAddr(host)
+		if err != nil {
+			return host
+		}
+
+		if !isTrusted(addr) {
+			return addr.Unmap().String()
+		}
+	}
+
+	// each proxy appends the address it received the request from, so
+	// read from the end
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+
+		addr = hop
+		if !isTrusted(hop) {
+			break
+		}
+	}
+
+	// a unix socket peer that didn't say who it's forwarding for
+	if !addr.IsValid() {
+		return r.RemoteAddr
+	}
+
+	return addr.Unmap().String()
+}
@@ -0,0 +1,73 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	err := Init("127.0.0.1, ::1,10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { trusted = nil })
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"direct", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"direct spoofed", "203.0.113.5:1234", []string{"198.51.100.7"}, "203.0.113.5"},
+		{"proxied", "127.0.0.1:80", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"proxied spoofed", "127.0.0.1:80", []string{"6.6.6.6, 198.51.100.7"}, "198.51.100.7"},
+		{"proxied spoofed headers", "127.0.0.1:80", []string{"6.6.6.6", "198.51.100.7"}, "198.51.100.7"},
+		{"proxy chain", "127.0.0.1:80", []string{"6.6.6.6, 198.51.100.7, 10.1.2.3"}, "198.51.100.7"},
+		{"only proxies", "127.0.0.1:80", []string{"10.1.2.3"}, "10.1.2.3"},
+		{"proxied garbage", "127.0.0.1:80", []string{"nonsense"}, "127.0.0.1"},
+		{"proxied nothing", "127.0.0.1:80", nil, "127.0.0.1"},
+		{"ipv6 direct", "[2001:db8::5]:443", nil, "2001:db8::5"},
+		{"ipv6 direct spoofed", "[2001:db8::5]:443", []string{"2001:db8::1"}, "2001:db8::5"},
+		{"ipv6 proxied", "[::1]:80", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"ipv6 proxied spoofed", "[::1]:80", []string{"6.6.6.6, 2001:db8::1"}, "2001:db8::1"},
+		{"ipv4 mapped", "[::ffff:203.0.113.5]:1234", nil, "203.0.113.5"},
+		{"ipv4 mapped proxy", "[::ffff:127.0.0.1]:80", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"unix", "@", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"unix unnamed", "", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"unix spoofed", "@", []string{"6.6.6.6, 198.51.100.7"}, "198.51.100.7"},
+		{"unix nothing", "@", nil, "@"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		for _, v := range tt.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+
+		got := ClientIP(r)
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
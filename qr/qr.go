@@ -0,0 +1,413 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package qr
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+var ErrTooLong = errors.New("data is too long for a qr code")
+
+// block layout for error correction level M, indexed by version
+var versions = [...]struct {
+	ec     int       // error correction codewords per block
+	groups [2][2]int // block count and data codewords per block
+	align  []int     // alignment pattern centres
+}{
+	1: {10, [2][2]int{{1, 16}}, nil},
+	2: {16, [2][2]int{{1, 28}}, []int{6, 18}},
+	3: {26, [2][2]int{{1, 44}}, []int{6, 22}},
+	4: {18, [2][2]int{{2, 32}}, []int{6, 26}},
+	5: {24, [2][2]int{{2, 43}}, []int{6, 30}},
+	6: {16, [2][2]int{{4, 27}}, []int{6, 34}},
+	7: {18, [2][2]int{{4, 31}}, []int{6, 22, 38}},
+	8: {22, [2][2]int{{2, 38}, {2, 39}}, []int{6, 24, 42}},
+	9: {22, [2][2]int{{3, 36}, {2, 37}}, []int{6, 26, 46}},
+}
+
+// Code is an encoded QR code
+type Code struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode returns the smallest QR code holding data
+func Encode(data []byte) (*Code, error) {
+	for version := 1; version < len(versions); version++ {
+		v := versions[version]
+
+		capacity := v.groups[0][0]*v.groups[0][1] + v.groups[1][0]*v.groups[1][1]
+		if 2+len(data) > capacity { // mode and count take 12 bits
+			continue
+		}
+
+		c := &Code{size: version*4 + 17}
+		c.modules = make([][]bool, c.size)
+		c.function = make([][]bool, c.size)
+		for i := range c.size {
+			c.modules[i] = make([]bool, c.size)
+			c.function[i] = make([]bool, c.size)
+		}
+
+		c.drawFunctionPatterns(version)
+		c.drawCodewords(codewords(version, data, capacity))
+
+		// pick the mask that's easiest to scan
+		best, bestPenalty := 0, -1
+		for mask := range 8 {
+			c.applyMask(mask)
+			c.drawFormat(mask)
+			penalty := c.penalty()
+			if bestPenalty == -1 || penalty < bestPenalty {
+				best, bestPenalty = mask, penalty
+			}
+			c.applyMask(mask) // masking twice undoes it
+		}
+
+		c.applyMask(best)
+		c.drawFormat(best)
+
+		return c, nil
+	}
+
+	return nil, ErrTooLong
+}
+
+// codewords builds the data bitstream and interleaves it with the error
+// correction codewords
+func codewords(version int, data []byte, capacity int) []byte {
+	var bits bitBuffer
+	bits.append(0b0100, 4) // byte mode
+	bits.append(len(data), 8)
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	// terminator, then pad to a whole byte
+	bits.append(0, min(4, capacity*8-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+
+	stream := bits.bytes()
+	for pad := byte(0xEC); len(stream) < capacity; pad ^= 0xEC ^ 0x11 {
+		stream = append(stream, pad)
+	}
+
+	v := versions[version]
+	divisor := rsDivisor(v.ec)
+
+	var blocks, ecBlocks [][]byte
+	for _, g := range v.groups {
+		for range g[0] {
+			block := stream[:g[1]]
+			stream = stream[g[1]:]
+
+			blocks = append(blocks, block)
+			ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+		}
+	}
+
+	var result []byte
+	for i := range blocks[len(blocks)-1] {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := range v.ec {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+
+	return result
+}
+
+func (c *Code) set(x int, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns(version int) {
+	// timing patterns
+	for i := range c.size {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	// finder patterns and their separators
+	for _, corner := range [][2]int{{3, 3}, {c.size - 4, 3}, {3, c.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x < 0 || x >= c.size || y < 0 || y >= c.size {
+					continue
+				}
+
+				d := max(abs(dx), abs(dy))
+				c.set(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	// alignment patterns, except where they'd overlap the finders
+	align := versions[version].align
+	for i, ay := range align {
+		for j, ax := range align {
+			if (i == 0 && j == 0) || (i == 0 && j == len(align)-1) || (i == len(align)-1 && j == 0) {
+				continue
+			}
+
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// reserve the format areas, they're drawn once the mask is known
+	c.drawFormat(0)
+
+	// version information
+	if version >= 7 {
+		rem := version
+		for range 12 {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+
+		for i := range 18 {
+			dark := (bits>>i)&1 != 0
+			a, b := c.size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+func (c *Code) drawFormat(mask int) {
+	data := 0b00<<3 | mask // level M
+	rem := data
+	for range 10 {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	// around the top left finder
+	for i := range 6 {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	// split between the other two finders
+	for i := range 8 {
+		c.set(c.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.size-15+i, bit(i))
+	}
+	c.set(8, c.size-8, true) // always dark
+}
+
+// drawCodewords places the data in the zigzag pattern, right to left in
+// pairs of columns
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 { // skip the vertical timing pattern
+			right = 5
+		}
+
+		for vert := range c.size {
+			for j := range 2 {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 { // upwards
+					y = c.size - 1 - vert
+				}
+
+				if c.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+
+				c.modules[y][x] = (data[i>>3]>>(7-i&7))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := range c.size {
+		for x := range c.size {
+			if c.function[y][x] {
+				continue
+			}
+
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+
+			c.modules[y][x] = c.modules[y][x] != invert
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan, lower is better
+func (c *Code) penalty() int {
+	var penalty, dark int
+
+	get := func(x, y int, vertical bool) bool {
+		if vertical {
+			return c.modules[x][y]
+		}
+
+		return c.modules[y][x]
+	}
+
+	finder := []bool{true, false, true, true, true, false, true}
+
+	for _, vertical := range []bool{false, true} {
+		for y := range c.size {
+			run := 0
+			for x := range c.size {
+				// runs of five or more of the same colour
+				if x > 0 && get(x, y, vertical) == get(x-1, y, vertical) {
+					run++
+					if run == 5 {
+						penalty += 3
+					} else if run > 5 {
+						penalty++
+					}
+				} else {
+					run = 1
+				}
+
+				// patterns that look like finders, with four light
+				// modules on either side
+				if x+7 > c.size {
+					continue
+				}
+
+				match := true
+				for i, want := range finder {
+					if get(x+i, y, vertical) != want {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+
+				light := func(from, to int) bool {
+					for i := from; i < to; i++ {
+						if i >= 0 && i < c.size && get(i, y, vertical) {
+							return false
+						}
+					}
+
+					return true
+				}
+				if light(x-4, x) || light(x+7, x+11) {
+					penalty += 40
+				}
+			}
+		}
+	}
+
+	for y := range c.size {
+		for x := range c.size {
+			if c.modules[y][x] {
+				dark++
+			}
+
+			// 2x2 blocks of the same colour
+			if x > 0 && y > 0 {
+				m := c.modules[y][x]
+				if m == c.modules[y][x-1] && m == c.modules[y-1][x] && m == c.modules[y-1][x-1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	// deviation from half the modules being dark
+	total := c.size * c.size
+	penalty += abs(dark*20-total*10) / total * 10
+
+	return penalty
+}
+
+// Image renders the code with scale pixels per module and the required
+// four module quiet zone
+func (c *Code) Image(scale int) *image.Gray {
+	size := (c.size + 8) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+
+	for y := range size {
+		for x := range size {
+			mx, my := x/scale-4, y/scale-4
+
+			v := color.Gray{Y: 255}
+			if mx >= 0 && mx < c.size && my >= 0 && my < c.size && c.modules[my][mx] {
+				v = color.Gray{Y: 0}
+			}
+
+			img.SetGray(x, y, v)
+		}
+	}
+
+	return img
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
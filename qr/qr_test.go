@@ -0,0 +1,126 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package qr
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func uri(account string) []byte {
+	return []byte("otpauth://totp/Betablock:" + account + "?issuer=Betablock&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP")
+}
+
+// mask reads the mask back out of the format information
+func (c *Code) mask() int {
+	var bits int
+	for i := 10; i < 13; i++ {
+		if c.modules[8][14-i] {
+			bits |= 1 << (i - 10)
+		}
+	}
+
+	return bits ^ (0x5412>>10)&7
+}
+
+func TestVersions(t *testing.T) {
+	tests := []struct {
+		length  int
+		version int
+	}{
+		{1, 1}, {14, 1}, {15, 2}, {26, 2}, {27, 3}, {42, 3}, {43, 4}, {62, 4},
+		{63, 5}, {84, 5}, {85, 6}, {106, 6}, {107, 7}, {122, 7}, {123, 8},
+		{152, 8}, {153, 9}, {180, 9},
+	}
+
+	for _, tt := range tests {
+		c, err := Encode(make([]byte, tt.length))
+		if err != nil {
+			t.Errorf("%d bytes: %s", tt.length, err)
+			continue
+		}
+
+		if c.size != tt.version*4+17 {
+			t.Errorf("%d bytes: size %d, want version %d", tt.length, c.size, tt.version)
+		}
+	}
+
+	_, err := Encode(make([]byte, 181))
+	if !errors.Is(err, ErrTooLong) {
+		t.Errorf("181 bytes: got %v, want %v", err, ErrTooLong)
+	}
+}
+
+// the versions and masks a reference encoder picks for otpauth URIs
+func TestURIs(t *testing.T) {
+	tests := []struct {
+		account string
+		version int
+		mask    int
+	}{
+		{"abc", 6, 1},
+		{"Notch", 6, 4},
+		{"ABCDEFGHIJKLMNOP", 6, 2},
+		{"abcdefghijklmnopqrstuvwxyz012345", 7, 4},
+	}
+
+	for _, tt := range tests {
+		c, err := Encode(uri(tt.account))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.size != tt.version*4+17 {
+			t.Errorf("%s: size %d, want version %d", tt.account, c.size, tt.version)
+		}
+		if c.mask() != tt.mask {
+			t.Errorf("%s: mask %d, want %d", tt.account, c.mask(), tt.mask)
+		}
+	}
+}
+
+func TestCodewords(t *testing.T) {
+	// version 6-M, four blocks of 27 data and 16 error correction codewords
+	want := "45e6575476f74385f746d4044736a4b30683253517f635055797758546379300833734eca2564511f25704ecf723541146d485ecf72604114757b3ec02463511f41605ec2626851157c604ec46f6a411163625ec26b23511c66775ecf6369311365634ecb3374511a42604ec480a3" +
+		"17cc8dbf78732cf9a009fde593e5e59f209ae75072593838d5425991771c8f8bb3890bbf9e5ddd9b0392911d0ed577c2ff28b1bddd1e05d9a8a4a9737ae"
+
+	got := hex.EncodeToString(codewords(6, uri("Notch"), 4*27))
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestImage(t *testing.T) {
+	c, err := Encode(uri("Notch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := c.Image(4)
+	if img.Bounds().Dx() != (41+8)*4 {
+		t.Errorf("image is %d pixels wide, want %d", img.Bounds().Dx(), (41+8)*4)
+	}
+
+	// quiet zone, then the top left finder's corner
+	if img.GrayAt(15, 15).Y != 255 || img.GrayAt(16, 16).Y != 0 {
+		t.Error("finder pattern isn't inside the quiet zone")
+	}
+
+}
@@ -0,0 +1,49 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package qr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// version 1-M "01234567" from ISO/IEC 18004 annex I
+	data := []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	want := []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55}
+
+	got := rsRemainder(data, rsDivisor(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+}
+
+func TestRSDivisor(t *testing.T) {
+	// the generator's roots are 2^0 to 2^(degree-1), so the remainder of a
+	// codeword the divisor was appended to is zero
+	for _, degree := range []int{10, 16, 18, 22, 24, 26} {
+		divisor := rsDivisor(degree)
+
+		codeword := append([]byte{1}, divisor...)
+		got := rsRemainder(codeword, divisor)
+		if !bytes.Equal(got, make([]byte, degree)) {
+			t.Errorf("degree %d: remainder % X isn't zero", degree, got)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// idle entries are swept at most this often
+const sweepInterval = time.Minute
+
+// Memory keeps rate limiting state in the process, so limits only apply per
+// server
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	failures  map[string]*failures
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when it'll be full again and can be dropped
+}
+
+type failures struct {
+	count  int
+	last   time.Time
+	window time.Duration
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket), failures: make(map[string]*failures)}
+}
+
+// sweep drops full buckets and forgotten failures so the maps don't grow
+// without bound. mu must be held.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+
+	m.lastSweep = now
+
+	for key, b := range m.buckets {
+		if now.After(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+	for key, f := range m.failures {
+		if now.Sub(f.last) > f.window {
+			delete(m.failures, key)
+		}
+	}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := clock()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = min(float64(burst), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second)), nil
+	}
+
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+
+	return true, 0, nil
+}
+
+func (m *Memory) Fail(ctx context.Context, key string, window time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := clock()
+	m.sweep(now)
+
+	f, ok := m.failures[key]
+	if !ok || now.Sub(f.last) > window {
+		f = &failures{}
+		m.failures[key] = f
+	}
+
+	f.count++
+	f.last = now
+	f.window = window
+
+	return nil
+}
+
+func (m *Memory) Failures(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.failures[key]
+	if !ok || clock().Sub(f.last) > window {
+		return 0, time.Time{}, nil
+	}
+
+	return f.count, f.last, nil
+}
+
+func (m *Memory) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+
+	return nil
+}
@@ -0,0 +1,183 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryTake(t *testing.T) {
+	advance := fakeClock(t)
+	ctx := context.Background()
+
+	m := NewMemory()
+
+	take := func() (bool, time.Duration) {
+		ok, wait, err := m.Take(ctx, "key", 0.5, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ok, wait
+	}
+
+	// the bucket starts full
+	for i := range 3 {
+		ok, _ := take()
+		if !ok {
+			t.Fatalf("take %d of the burst was refused", i+1)
+		}
+	}
+
+	ok, wait := take()
+	if ok || wait != time.Second*2 {
+		t.Errorf("empty bucket: got %t, %s, want false, 2s", ok, wait)
+	}
+
+	advance(time.Second)
+	ok, wait = take()
+	if ok || wait != time.Second {
+		t.Errorf("half a token: got %t, %s, want false, 1s", ok, wait)
+	}
+
+	advance(time.Second)
+	ok, _ = take()
+	if !ok {
+		t.Error("refilled token was refused")
+	}
+
+	// refilling stops at the burst
+	advance(time.Hour)
+	for i := range 3 {
+		ok, _ = take()
+		if !ok {
+			t.Fatalf("take %d after refilling was refused", i+1)
+		}
+	}
+
+	ok, _ = take()
+	if ok {
+		t.Error("bucket refilled past its burst")
+	}
+
+	// other keys have their own buckets
+	ok, _, err := m.Take(ctx, "other", 0.5, 3)
+	if err != nil || !ok {
+		t.Errorf("other key: got %t, %v", ok, err)
+	}
+}
+
+func TestMemoryFail(t *testing.T) {
+	advance := fakeClock(t)
+	ctx := context.Background()
+
+	m := NewMemory()
+
+	for range 3 {
+		err := m.Fail(ctx, "key", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		advance(time.Second * 30)
+	}
+
+	// each failure extends the window
+	count, last, err := m.Failures(ctx, "key", time.Minute)
+	if err != nil || count != 3 || !last.Equal(clock().Add(-time.Second*30)) {
+		t.Errorf("got %d failures, last %s, %v, want 3 30s ago", count, last, err)
+	}
+
+	advance(time.Minute)
+	count, _, err = m.Failures(ctx, "key", time.Minute)
+	if err != nil || count != 0 {
+		t.Errorf("got %d failures after the window, %v", count, err)
+	}
+
+	// failing after the window starts counting again
+	err = m.Fail(ctx, "key", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, _, err = m.Failures(ctx, "key", time.Minute)
+	if err != nil || count != 1 {
+		t.Errorf("got %d failures after failing again, %v, want 1", count, err)
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	advance := fakeClock(t)
+	ctx := context.Background()
+
+	m := NewMemory()
+
+	_, _, err := m.Take(ctx, "bucket", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Fail(ctx, "failure", time.Minute*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bucket is full again after a second but the failure is remembered
+	advance(sweepInterval)
+	_, _, err = m.Take(ctx, "sweep", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.buckets["bucket"]; ok {
+		t.Error("full bucket wasn't swept")
+	}
+	if _, ok := m.failures["failure"]; !ok {
+		t.Error("remembered failure was swept")
+	}
+
+	// and the failure once its window has passed
+	advance(time.Minute * 5)
+	_, _, err = m.Take(ctx, "sweep", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.failures["failure"]; ok {
+		t.Error("forgotten failure wasn't swept")
+	}
+
+	// nothing is swept until another interval has passed
+	_, _, err = m.Take(ctx, "bucket", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	advance(sweepInterval / 2)
+	_, _, err = m.Take(ctx, "sweep", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.buckets["bucket"]; !ok {
+		t.Error("swept again before the interval passed")
+	}
+}
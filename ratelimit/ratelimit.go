@@ -0,0 +1,160 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+)
+
+// Store keeps rate limiting state. The memory store is used unless a shared
+// one is set so that several servers can enforce the same limits.
+type Store interface {
+	// Take removes a token from the bucket at key, which refills at rate
+	// tokens per second up to burst. If the bucket is empty it returns false
+	// and how long until a token is available.
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+
+	// Fail records a failure at key. Failures older than window are
+	// forgotten.
+	Fail(ctx context.Context, key string, window time.Duration) error
+
+	// Failures returns the number of failures recorded at key within window
+	// and when the last one happened.
+	Failures(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+
+	// Reset forgets the failures at key.
+	Reset(ctx context.Context, key string) error
+}
+
+var store Store = NewMemory()
+
+// clock tells the time limits are measured against, and is replaced in tests
+var clock = time.Now
+
+func Init(s Store) {
+	store = s
+}
+
+// Limit is a token bucket rate
+type Limit struct {
+	Rate  float64 // tokens per second
+	Burst int
+}
+
+// PerMinute returns a limit allowing n requests a minute with bursts of n
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// Allow reports whether a request counted against key is within limit, and
+// if not, how long until it would be
+func Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	return store.Take(ctx, key, limit.Rate, limit.Burst)
+}
+
+// Lockout is an exponential backoff applied after repeated failures
+type Lockout struct {
+	Threshold int           // failures allowed before locking
+	Base      time.Duration // first lock duration, doubled with each further failure
+	Max       time.Duration
+	Window    time.Duration // how long failures are remembered
+}
+
+// wait returns how long the lockout lasts after the given failures
+func (l Lockout) wait(failures int, last time.Time) time.Duration {
+	if failures < l.Threshold {
+		return 0
+	}
+
+	d := time.Duration(float64(l.Base) * math.Pow(2, float64(failures-l.Threshold)))
+	if d > l.Max || d <= 0 {
+		d = l.Max
+	}
+
+	return max(last.Add(d).Sub(clock()), 0)
+}
+
+// Locked returns how long key remains locked out, or zero if it isn't
+func Locked(ctx context.Context, key string, lockout Lockout) (time.Duration, error) {
+	failures, last, err := store.Failures(ctx, key, lockout.Window)
+	if err != nil {
+		return 0, err
+	}
+
+	return lockout.wait(failures, last), nil
+}
+
+func Fail(ctx context.Context, key string, lockout Lockout) error {
+	return store.Fail(ctx, key, lockout.Window)
+}
+
+func Reset(ctx context.Context, key string) error {
+	return store.Reset(ctx, key)
+}
+
+var (
+	// LoginRate limits password checks from one address, whether they
+	// succeed or not, so bcrypt can't be used to exhaust the CPU
+	LoginRate = PerMinute(20)
+
+	// AccountLockout slows guessing against one account from anywhere
+	AccountLockout = Lockout{Threshold: 5, Base: time.Second * 30, Max: time.Hour, Window: time.Hour * 24}
+
+	// AddressLockout slows guessing across many accounts from one address.
+	// It's more lenient since addresses can be shared.
+	AddressLockout = Lockout{Threshold: 20, Base: time.Minute, Max: time.Hour, Window: time.Hour * 24}
+)
+
+// CheckLogin returns how long the client at ip must wait before trying to
+// log in to username, or zero if it may try now
+func CheckLogin(ctx context.Context, ip string, username string) (time.Duration, error) {
+	ok, wait, err := Allow(ctx, "login:"+ip, LoginRate)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return wait, nil
+	}
+
+	wait, err = Locked(ctx, "login-account:"+strings.ToLower(username), AccountLockout)
+	if err != nil || wait != 0 {
+		return wait, err
+	}
+
+	return Locked(ctx, "login-address:"+ip, AddressLockout)
+}
+
+// LoginFailed counts a wrong password against both the account and address
+func LoginFailed(ctx context.Context, ip string, username string) error {
+	err := Fail(ctx, "login-account:"+strings.ToLower(username), AccountLockout)
+	if err != nil {
+		return err
+	}
+
+	return Fail(ctx, "login-address:"+ip, AddressLockout)
+}
+
+// LoginSucceeded clears the account's failures. The address's are left to
+// expire so one valid account can't be used to reset them.
+func LoginSucceeded(ctx context.Context, username string) error {
+	return Reset(ctx, "login-account:"+strings.ToLower(username))
+}
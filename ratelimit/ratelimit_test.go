@@ -0,0 +1,133 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock stops the clock at a fixed time for the rest of the test and
+// returns a function that moves it forward
+func fakeClock(t *testing.T) func(time.Duration) {
+	t.Helper()
+
+	current := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock = func() time.Time { return current }
+	t.Cleanup(func() { clock = time.Now })
+
+	return func(d time.Duration) {
+		current = current.Add(d)
+	}
+}
+
+func TestLockoutWait(t *testing.T) {
+	fakeClock(t)
+
+	lockout := Lockout{Threshold: 3, Base: time.Second * 10, Max: time.Minute, Window: time.Hour}
+	last := clock()
+
+	for _, c := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second * 10},
+		{4, time.Second * 20},
+		{5, time.Second * 40},
+		{6, time.Minute},
+		{1000, time.Minute}, // the doubling overflows
+	} {
+		got := lockout.wait(c.failures, last)
+		if got != c.want {
+			t.Errorf("%d failures: waits %s, want %s", c.failures, got, c.want)
+		}
+	}
+
+	// the lock runs from the last failure
+	got := lockout.wait(4, last.Add(-time.Second*15))
+	if got != time.Second*5 {
+		t.Errorf("failure 15s ago: waits %s, want 5s", got)
+	}
+
+	got = lockout.wait(4, last.Add(-time.Minute))
+	if got != 0 {
+		t.Errorf("failure a minute ago: waits %s, want 0", got)
+	}
+}
+
+func TestLocked(t *testing.T) {
+	advance := fakeClock(t)
+	ctx := context.Background()
+
+	Init(NewMemory())
+	t.Cleanup(func() { Init(NewMemory()) })
+
+	lockout := Lockout{Threshold: 2, Base: time.Second * 10, Max: time.Minute, Window: time.Hour}
+
+	fail := func() {
+		err := Fail(ctx, "key", lockout)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	locked := func() time.Duration {
+		wait, err := Locked(ctx, "key", lockout)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return wait
+	}
+
+	fail()
+	if wait := locked(); wait != 0 {
+		t.Errorf("locked for %s below the threshold", wait)
+	}
+
+	fail()
+	if wait := locked(); wait != time.Second*10 {
+		t.Errorf("locked for %s at the threshold, want 10s", wait)
+	}
+
+	advance(time.Second * 10)
+	fail()
+	if wait := locked(); wait != time.Second*20 {
+		t.Errorf("locked for %s after another failure, want 20s", wait)
+	}
+
+	// failures are forgotten after the window
+	advance(lockout.Window + time.Second)
+	if wait := locked(); wait != 0 {
+		t.Errorf("locked for %s after the window", wait)
+	}
+
+	fail()
+	fail()
+	err := Reset(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wait := locked(); wait != 0 {
+		t.Errorf("locked for %s after a reset", wait)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid object key")
+
+// ValidKey checks that key is a clean relative path that can't refer to
+// anything outside of the store. Hidden path elements (starting with a dot)
+// are rejected so temporary and metadata files are never addressable.
+func ValidKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "\\\x00") {
+		return ErrInvalidKey
+	}
+
+	for _, elem := range strings.Split(key, "/") {
+		if elem == "" || strings.HasPrefix(elem, ".") {
+			return ErrInvalidKey
+		}
+	}
+
+	return nil
+}
+
+// validPrefix is like ValidKey but allows the trailing slash of a directory
+// style prefix, as well as the empty prefix
+func validPrefix(prefix string) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return nil
+	}
+
+	return ValidKey(prefix)
+}
@@ -0,0 +1,82 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"skins/Notch.png", true},
+		{"resources/sound/step/grass1.ogg", true},
+		{"a", true},
+		{"", false},
+		{"/skins/Notch.png", false},
+		{"skins//Notch.png", false},
+		{"skins/", false},
+		{"../public/skins/Notch.png", false},
+		{"skins/../capes/Notch.png", false},
+		{"skins/./Notch.png", false},
+		{".tmp-1-Notch.png", false},
+		{"skins/.hidden/Notch.png", false},
+		{`skins\..\Notch.png`, false},
+		{"skins/Notch.png\x00.txt", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidKey(tt.key)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidKey(%q) = %v, want valid %t", tt.key, err, tt.valid)
+		}
+	}
+}
+
+func FuzzKey(f *testing.F) {
+	for _, seed := range []string{"skins/Notch.png", "../x", "a/./b", "a//b", "/a", `a\b`, ".a", "a/.b/c", "a/..", "..", "\x00"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, key string) {
+		if ValidKey(key) != nil {
+			return
+		}
+
+		// anything accepted has to stay inside the store
+		if path.Clean(key) != key || path.IsAbs(key) || !filepath.IsLocal(filepath.FromSlash(key)) {
+			t.Fatalf("ValidKey accepted %q, which isn't a clean local path", key)
+		}
+
+		for _, elem := range strings.Split(key, "/") {
+			if strings.HasPrefix(elem, ".") {
+				t.Fatalf("ValidKey accepted %q, which has a hidden element", key)
+			}
+		}
+
+		if validPrefix(key+"/") != nil {
+			t.Fatalf("validPrefix rejected %q, the prefix form of a valid key", key+"/")
+		}
+	})
+}
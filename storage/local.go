@@ -0,0 +1,358 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SymlinkPolicy controls how symbolic links below a local store are treated
+type SymlinkPolicy string
+
+const (
+	SymlinksDeny   SymlinkPolicy = "deny"   // never follow symlinks
+	SymlinksRoot   SymlinkPolicy = "root"   // follow symlinks that stay inside the store
+	SymlinksFollow SymlinkPolicy = "follow" // follow symlinks anywhere
+)
+
+var ErrSymlink = errors.New("object is a symbolic link")
+
+type indexEntry struct {
+	size     int64
+	modified time.Time
+	hash     string
+}
+
+// Local stores objects as files below a directory on the local filesystem.
+// Writes, deletes and listings go through an os.Root so paths can't escape the
+// directory. Reads do too, except under SymlinksFollow, where links are
+// resolved by the operating system wherever they point.
+type Local struct {
+	dir      string
+	root     *os.Root
+	symlinks SymlinkPolicy
+
+	// caches hashes so listings and ETags don't rehash unchanged files
+	indexLock sync.RWMutex
+	index     map[string]indexEntry
+}
+
+func NewLocal(dir string, symlinks SymlinkPolicy) (*Local, error) {
+	switch symlinks {
+	case "":
+		symlinks = SymlinksRoot
+	case SymlinksDeny, SymlinksRoot, SymlinksFollow:
+	default:
+		return nil, fmt.Errorf("unknown symlink policy %q", symlinks)
+	}
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Local{dir: dir, root: root, symlinks: symlinks, index: make(map[string]indexEntry)}, nil
+}
+
+// checkSymlinks rejects keys that pass through a symlink when they're denied
+func (l *Local) checkSymlinks(key string) error {
+	if l.symlinks != SymlinksDeny {
+		return nil
+	}
+
+	elems := strings.Split(key, "/")
+	for i := range elems {
+		stat, err := l.root.Lstat(path.Join(elems[:i+1]...))
+		if err != nil {
+			return err
+		}
+		if stat.Mode()&fs.ModeSymlink != 0 {
+			return ErrSymlink
+		}
+	}
+
+	return nil
+}
+
+// rootErr reports errors caused by a symlink escaping the root as ErrSymlink,
+// as that's the only way a valid key can fail to resolve inside of it
+func (l *Local) rootErr(key string, err error) error {
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	elems := strings.Split(key, "/")
+	for i := range elems {
+		stat, lerr := l.root.Lstat(path.Join(elems[:i+1]...))
+		if lerr != nil {
+			break
+		}
+		if stat.Mode()&fs.ModeSymlink != 0 {
+			return ErrSymlink
+		}
+	}
+
+	return err
+}
+
+func (l *Local) open(key string) (*os.File, error) {
+	if l.symlinks == SymlinksFollow {
+		return os.Open(filepath.Join(l.dir, filepath.FromSlash(key)))
+	}
+
+	err := l.checkSymlinks(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := l.root.Open(key)
+	if err != nil {
+		return nil, l.rootErr(key, err)
+	}
+
+	return f, nil
+}
+
+func (l *Local) stat(key string) (fs.FileInfo, error) {
+	if l.symlinks == SymlinksFollow {
+		return os.Stat(filepath.Join(l.dir, filepath.FromSlash(key)))
+	}
+
+	err := l.checkSymlinks(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := l.root.Stat(key)
+	if err != nil {
+		return nil, l.rootErr(key, err)
+	}
+
+	return stat, nil
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadSeekCloser, Info, error) {
+	f, err := l.open(key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Info{}, err
+	}
+	if !stat.Mode().IsRegular() {
+		f.Close()
+		return nil, Info{}, ErrNotExist
+	}
+
+	info, err := l.info(key, stat)
+	if err != nil {
+		f.Close()
+		return nil, Info{}, err
+	}
+
+	return f, info, nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (Info, error) {
+	stat, err := l.stat(key)
+	if err != nil {
+		return Info{}, err
+	}
+	if !stat.Mode().IsRegular() {
+		return Info{}, ErrNotExist
+	}
+
+	return l.info(key, stat)
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	// create parent directories
+	elems := strings.Split(key, "/")
+	for i := range len(elems) - 1 {
+		err := l.root.Mkdir(path.Join(elems[:i+1]...), 0755)
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+
+	if dir := path.Dir(key); dir != "." {
+		err := l.checkSymlinks(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	// write to a hidden temporary file first so readers never see a partial object
+	tmp := path.Join(path.Dir(key), fmt.Sprintf(".tmp-%d-%s", time.Now().UnixNano(), path.Base(key)))
+
+	f, err := l.root.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer l.root.Remove(tmp)
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return l.root.Rename(tmp, key)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	err := l.checkSymlinks(key)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	err = l.root.Remove(key)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *Local) List(ctx context.Context, prefix string) ([]Info, error) {
+	// walk from the deepest directory contained in the prefix
+	base := "."
+	if i := strings.LastIndex(prefix, "/"); i != -1 {
+		base = prefix[:i]
+	}
+
+	var objects []Info
+
+	err := fs.WalkDir(l.root.FS(), base, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && name == base {
+				return fs.SkipAll
+			}
+
+			return err
+		}
+
+		// hidden files and directories are never listed
+		if name != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		if d.IsDir() || !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+
+		var stat fs.FileInfo
+		if d.Type()&fs.ModeSymlink != 0 {
+			if l.symlinks == SymlinksDeny {
+				return nil
+			}
+
+			// skip symlinks that are dangling or point outside of the root
+			stat, err = l.stat(name)
+			if err != nil || !stat.Mode().IsRegular() {
+				return nil
+			}
+		} else {
+			stat, err = d.Info()
+			if err != nil {
+				return err
+			}
+		}
+
+		info, err := l.info(name, stat)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, info)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(objects, func(a, b Info) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return objects, nil
+}
+
+// info builds the object info for a file, rehashing it only when its size or
+// modification time changed since it was last seen
+func (l *Local) info(key string, stat fs.FileInfo) (Info, error) {
+	info := Info{Key: key, Size: stat.Size(), Modified: stat.ModTime()}
+
+	l.indexLock.RLock()
+	e, ok := l.index[key]
+	l.indexLock.RUnlock()
+
+	if ok && e.size == stat.Size() && e.modified.Equal(stat.ModTime()) {
+		info.Hash = e.hash
+		return info, nil
+	}
+
+	f, err := l.open(key)
+	if err != nil {
+		return Info{}, err
+	}
+
+	defer f.Close()
+
+	hash := md5.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return Info{}, err
+	}
+
+	info.Hash = hex.EncodeToString(hash.Sum(nil))
+
+	l.indexLock.Lock()
+	l.index[key] = indexEntry{size: info.Size, modified: info.Modified, hash: info.Hash}
+	l.indexLock.Unlock()
+
+	return info, nil
+}
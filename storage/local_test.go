@@ -0,0 +1,157 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// symlinkStore makes a store with a plain file, a link to it, a link to a
+// directory inside the store and links that escape it
+func symlinkStore(t *testing.T, policy SymlinkPolicy) *Local {
+	base := t.TempDir()
+	dir := filepath.Join(base, "public")
+	outside := filepath.Join(base, "secret")
+
+	for _, d := range []string{filepath.Join(dir, "skins"), outside} {
+		err := os.MkdirAll(d, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]string{
+		filepath.Join(dir, "skins", "real.png"): "real",
+		filepath.Join(outside, "key.pem"):       "secret",
+	}
+	for p, content := range files {
+		err := os.WriteFile(p, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		filepath.Join(dir, "skins", "inside.png"): "real.png",
+		filepath.Join(dir, "linked"):              "skins",
+		filepath.Join(dir, "skins", "escape.png"): filepath.Join(outside, "key.pem"),
+		filepath.Join(dir, "outside"):             outside,
+	}
+	for p, target := range links {
+		err := os.Symlink(target, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := NewLocal(dir, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+func TestSymlinkPolicies(t *testing.T) {
+	keys := []string{"skins/real.png", "skins/inside.png", "linked/real.png", "skins/escape.png", "outside/key.pem"}
+
+	tests := []struct {
+		policy   SymlinkPolicy
+		readable []string // every other key has to fail with ErrSymlink
+		listed   []string
+	}{
+		{SymlinksDeny, []string{"skins/real.png"}, []string{"skins/real.png"}},
+		{SymlinksRoot, []string{"skins/real.png", "skins/inside.png", "linked/real.png"}, []string{"skins/inside.png", "skins/real.png"}},
+		{SymlinksFollow, keys, []string{"skins/escape.png", "skins/inside.png", "skins/real.png"}},
+	}
+
+	for _, tt := range tests {
+		l := symlinkStore(t, tt.policy)
+
+		for _, key := range keys {
+			f, _, err := l.Open(context.Background(), key)
+			if !slices.Contains(tt.readable, key) {
+				if !errors.Is(err, ErrSymlink) {
+					t.Errorf("%s: opening %s returned %v, want ErrSymlink", tt.policy, key, err)
+				}
+				if err == nil {
+					f.Close()
+				}
+
+				continue
+			}
+
+			if err != nil {
+				t.Errorf("%s: opening %s returned %v", tt.policy, key, err)
+				continue
+			}
+
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil || len(data) == 0 {
+				t.Errorf("%s: reading %s returned %q, %v", tt.policy, key, data, err)
+			}
+		}
+
+		objects, err := l.List(context.Background(), "skins/")
+		if err != nil {
+			t.Fatalf("%s: list: %s", tt.policy, err)
+		}
+
+		var listed []string
+		for _, o := range objects {
+			listed = append(listed, o.Key)
+		}
+
+		if !slices.Equal(listed, tt.listed) {
+			t.Errorf("%s: listed %q, want %q", tt.policy, listed, tt.listed)
+		}
+	}
+}
+
+func TestSymlinkPut(t *testing.T) {
+	l := symlinkStore(t, SymlinksDeny)
+
+	err := l.Put(context.Background(), "linked/new.png", strings.NewReader("new"), 3)
+	if !errors.Is(err, ErrSymlink) {
+		t.Errorf("writing through a linked directory returned %v, want ErrSymlink", err)
+	}
+
+	// writes never leave the store, whatever the policy
+	for _, policy := range []SymlinkPolicy{SymlinksDeny, SymlinksRoot, SymlinksFollow} {
+		l := symlinkStore(t, policy)
+
+		err := l.Put(context.Background(), "outside/new.pem", strings.NewReader("new"), 3)
+		if err == nil {
+			t.Errorf("%s: writing through a link out of the store succeeded", policy)
+		}
+
+		_, err = os.Lstat(filepath.Join(l.dir, "..", "secret", "new.pem"))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: the write landed outside the store: %v", policy, err)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 stores objects in a bucket on an S3 compatible service such as MinIO,
+// using path style addressing and AWS signature version 4
+type S3 struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+
+	client *http.Client
+}
+
+func NewS3(endpoint string, region string, bucket string, accessKey string, secretKey string) (*S3, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported endpoint scheme %q", u.Scheme)
+	}
+	if bucket == "" {
+		return nil, errors.New("no bucket specified")
+	}
+
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadSeekCloser, Info, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	return &s3Reader{ctx: ctx, s: s, key: key, size: info.Size}, info, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	resp, err := s.do(ctx, "HEAD", key, nil, nil, -1, nil)
+	if err != nil {
+		return Info{}, err
+	}
+
+	resp.Body.Close()
+
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	return Info{
+		Key:      key,
+		Size:     resp.ContentLength,
+		Modified: modified,
+		Hash:     strings.Trim(resp.Header.Get("ETag"), "\""),
+	}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	resp, err := s.do(ctx, "PUT", key, nil, r, size, nil)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	return nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, "DELETE", key, nil, nil, -1, nil)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	resp.Body.Close()
+
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]Info, error) {
+	var objects []Info
+
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		resp, err := s.do(ctx, "GET", "", query, nil, -1, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var res listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range res.Contents {
+			// directory placeholders created by some clients
+			if strings.HasSuffix(c.Key, "/") {
+				continue
+			}
+
+			objects = append(objects, Info{
+				Key:      c.Key,
+				Size:     c.Size,
+				Modified: c.LastModified,
+				Hash:     strings.Trim(c.ETag, "\""),
+			})
+		}
+
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			break
+		}
+
+		query.Set("continuation-token", res.NextContinuationToken)
+	}
+
+	slices.SortFunc(objects, func(a, b Info) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return objects, nil
+}
+
+// do sends a signed request for the given object key, or for the bucket
+// itself if the key is empty. Non-2xx responses are returned as errors.
+func (s *S3) do(ctx context.Context, method string, key string, query url.Values, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotExist
+		}
+
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+}
+
+// sign adds an AWS signature version 4 authorization header to req. The
+// payload isn't hashed, which S3 and MinIO allow for streamed uploads.
+func (s *S3) sign(req *http.Request, now time.Time) {
+	const payloadHash = "UNSIGNED-PAYLOAD"
+
+	date := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+
+	req.Header.Set("X-Amz-Date", timestamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + timestamp + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode percent-encodes s as required by signature version 4, leaving
+// slashes alone unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for k, vs := range query {
+		for _, v := range vs {
+			params = append(params, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+
+	slices.Sort(params)
+
+	return strings.Join(params, "&")
+}
+
+// s3Reader reads an object with ranged GET requests so seeking doesn't
+// require downloading what's skipped
+type s3Reader struct {
+	ctx  context.Context
+	s    *S3
+	key  string
+	size int64
+
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		header := http.Header{"Range": {"bytes=" + strconv.FormatInt(r.offset, 10) + "-"}}
+
+		resp, err := r.s.do(r.ctx, "GET", r.key, nil, nil, -1, header)
+		if err != nil {
+			return 0, err
+		}
+
+		r.body = resp.Body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+
+	return n, err
+}
+
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+
+	r.offset = offset
+
+	return offset, nil
+}
+
+func (r *s3Reader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+
+	return r.body.Close()
+}
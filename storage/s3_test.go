@@ -0,0 +1,353 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "minioadmin"
+	testSecretKey = "minio-secret"
+	testRegion    = "us-east-1"
+	testBucket    = "betablock"
+)
+
+// fakeS3 is a stand-in for a MinIO style server holding a single bucket. It
+// checks every request's signature itself and pages listings two at a time.
+type fakeS3 struct {
+	t *testing.T
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	lists   int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3) {
+	f := &fakeS3{t: t, objects: make(map[string][]byte)}
+
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	s, err := NewS3(srv.URL, testRegion, testBucket, testAccessKey, testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f, s
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := f.verify(r)
+	if err != nil {
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	if key == "" && r.Method == "GET" {
+		f.list(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		f.objects[key] = data
+	case "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case "HEAD", "GET":
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", "\""+hex.EncodeToString(sum[:])+"\"")
+		w.Header().Set("Last-Modified", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat))
+
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	f.lists++
+
+	query := r.URL.Query()
+	if query.Get("list-type") != "2" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, query.Get("prefix")) {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	start := 0
+	if token := query.Get("continuation-token"); token != "" {
+		var err error
+		start, err = strconv.Atoi(token)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	type content struct {
+		Key          string
+		Size         int
+		LastModified string
+		ETag         string
+	}
+
+	var res struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}
+
+	end := min(start+2, len(keys))
+	for _, key := range keys[start:end] {
+		sum := md5.Sum(f.objects[key])
+		res.Contents = append(res.Contents, content{Key: key, Size: len(f.objects[key]), LastModified: "2025-01-02T03:04:05.000Z", ETag: "\"" + hex.EncodeToString(sum[:]) + "\""})
+	}
+
+	if end < len(keys) {
+		res.IsTruncated = true
+		res.NextContinuationToken = strconv.Itoa(end)
+	}
+
+	xml.NewEncoder(w).Encode(res)
+}
+
+// verify checks the request's signature version 4 authorization the way S3
+// does, from the request as the server received it
+func (f *fakeS3) verify(r *http.Request) error {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	if !ok {
+		return errors.New("missing authorization")
+	}
+
+	fields := make(map[string]string)
+	for _, field := range strings.Split(auth, ", ") {
+		k, v, _ := strings.Cut(field, "=")
+		fields[k] = v
+	}
+
+	timestamp := r.Header.Get("X-Amz-Date")
+	date := strings.SplitN(timestamp, "T", 2)[0]
+	scope := date + "/" + testRegion + "/s3/aws4_request"
+
+	if fields["Credential"] != testAccessKey+"/"+scope {
+		return fmt.Errorf("unexpected credential %q", fields["Credential"])
+	}
+
+	var canonicalHeaders string
+	for _, h := range strings.Split(fields["SignedHeaders"], ";") {
+		value := r.Header.Get(h)
+		if h == "host" {
+			value = r.Host
+		}
+
+		canonicalHeaders += h + ":" + strings.TrimSpace(value) + "\n"
+	}
+
+	// query parameters sorted by name, encoded the way signing requires
+	var params []string
+	for k, vs := range r.URL.Query() {
+		for _, v := range vs {
+			params = append(params, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+
+	slices.Sort(params)
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		canonicalHeaders,
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{date, testRegion, "s3", "aws4_request"} {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(part))
+		key = h.Sum(nil)
+	}
+
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(stringToSign))
+
+	if !hmac.Equal([]byte(fields["Signature"]), []byte(hex.EncodeToString(h.Sum(nil)))) {
+		return errors.New("signature mismatch")
+	}
+
+	return nil
+}
+
+func TestS3Objects(t *testing.T) {
+	_, s := newFakeS3(t)
+	ctx := context.Background()
+
+	data := []byte("not really a png")
+	sum := md5.Sum(data)
+
+	// spaces and pluses have to be encoded the same way for the signature
+	key := "skins/some name+1.png"
+
+	err := s.Put(ctx, key, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("put: %s", err)
+	}
+
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	if info.Size != int64(len(data)) || info.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("stat returned %+v", info)
+	}
+
+	f, _, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+
+	defer f.Close()
+
+	_, err = f.Seek(4, io.SeekStart)
+	if err != nil {
+		t.Fatalf("seek: %s", err)
+	}
+
+	rest, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	if !bytes.Equal(rest, data[4:]) {
+		t.Errorf("read %q after seeking, want %q", rest, data[4:])
+	}
+
+	err = s.Delete(ctx, key)
+	if err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+
+	_, err = s.Stat(ctx, key)
+	if !errors.Is(err, ErrNotExist) {
+		t.Errorf("stat after delete returned %v, want ErrNotExist", err)
+	}
+
+	_, _, err = s.Open(ctx, "skins/missing.png")
+	if !errors.Is(err, ErrNotExist) {
+		t.Errorf("open of missing object returned %v, want ErrNotExist", err)
+	}
+
+	// deleting what isn't there isn't an error
+	err = s.Delete(ctx, "skins/missing.png")
+	if err != nil {
+		t.Errorf("delete of missing object returned %v", err)
+	}
+}
+
+func TestS3ListPagination(t *testing.T) {
+	f, s := newFakeS3(t)
+	ctx := context.Background()
+
+	want := []string{"resources/a.ogg", "resources/b/c.ogg", "resources/b/d.ogg", "resources/e.ogg", "resources/f.ogg"}
+	for _, key := range append([]string{"binaries/minecraft.jar", "resources/b/"}, want...) {
+		err := s.Put(ctx, key, strings.NewReader(key), int64(len(key)))
+		if err != nil {
+			t.Fatalf("put %s: %s", key, err)
+		}
+	}
+
+	objects, err := s.List(ctx, "resources/")
+	if err != nil {
+		t.Fatalf("list: %s", err)
+	}
+
+	var keys []string
+	for _, o := range objects {
+		keys = append(keys, o.Key)
+	}
+
+	if !slices.Equal(keys, want) {
+		t.Errorf("list returned %q, want %q", keys, want)
+	}
+	if f.lists < 3 {
+		t.Errorf("listing took %d requests, expected it to follow continuation tokens", f.lists)
+	}
+}
+
+func TestS3BadSignature(t *testing.T) {
+	_, s := newFakeS3(t)
+	s.secretKey = "wrong"
+
+	_, err := s.Stat(context.Background(), "skins/Notch.png")
+	if err == nil || errors.Is(err, ErrNotExist) {
+		t.Errorf("stat with the wrong secret returned %v, want a signature error", err)
+	}
+}
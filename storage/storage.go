@@ -0,0 +1,105 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"slices"
+	"time"
+)
+
+// ErrNotExist is returned when the requested object doesn't exist
+var ErrNotExist = fs.ErrNotExist
+
+type Info struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+	Hash     string // hex encoded MD5 of the contents
+}
+
+// Store is a flat object store addressed by slash separated keys such as
+// "skins/Notch.png"
+type Store interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, Info, error)
+	Stat(ctx context.Context, key string) (Info, error)
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]Info, error)
+}
+
+var store Store
+
+func Init(s Store) {
+	store = s
+}
+
+func Open(ctx context.Context, key string) (io.ReadSeekCloser, Info, error) {
+	err := ValidKey(key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	return store.Open(ctx, key)
+}
+
+func Stat(ctx context.Context, key string) (Info, error) {
+	err := ValidKey(key)
+	if err != nil {
+		return Info{}, err
+	}
+
+	return store.Stat(ctx, key)
+}
+
+func Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	err := ValidKey(key)
+	if err != nil {
+		return err
+	}
+
+	return store.Put(ctx, key, r, size)
+}
+
+func Delete(ctx context.Context, key string) error {
+	err := ValidKey(key)
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(ctx, key)
+}
+
+// List returns every object whose key starts with prefix, sorted by key.
+// Objects with keys that aren't valid are left out.
+func List(ctx context.Context, prefix string) ([]Info, error) {
+	err := validPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(objects, func(o Info) bool { return ValidKey(o.Key) != nil }), nil
+}
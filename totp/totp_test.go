@@ -0,0 +1,117 @@
+/*
+	betablock - block server emulator
+	Copyright (C) 2025  Pancakes <patapancakes@pagefault.games>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// the SHA-1 secret from RFC 6238 appendix B, base32 encoded
+var secret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// the appendix B codes, cut to the last six digits
+var vectors = []struct {
+	time int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCode(t *testing.T) {
+	for _, tt := range vectors {
+		got := Code([]byte("12345678901234567890"), Step(time.Unix(tt.time, 0)))
+		if got != tt.code {
+			t.Errorf("%d: got %s, want %s", tt.time, got, tt.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range vectors {
+		now := time.Unix(tt.time, 0)
+
+		step, ok := Validate(secret, tt.code, now, 0)
+		if !ok || step != Step(now) {
+			t.Errorf("%d: got step %d %t, want %d", tt.time, step, ok, Step(now))
+		}
+
+		// a code can't be used twice
+		_, ok = Validate(secret, tt.code, now, step)
+		if ok {
+			t.Errorf("%d: code was accepted again in the step it was used", tt.time)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code := Code([]byte("12345678901234567890"), Step(now))
+
+	tests := []struct {
+		offset time.Duration
+		ok     bool
+	}{
+		{-2 * period * time.Second, false},
+		{-period * time.Second, true},
+		{period * time.Second, true},
+		{2 * period * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		_, ok := Validate(secret, code, now.Add(tt.offset), 0)
+		if ok != tt.ok {
+			t.Errorf("%s: got %t, want %t", tt.offset, ok, tt.ok)
+		}
+	}
+
+	// a later step was already used, so this one is too old
+	_, ok := Validate(secret, code, now, Step(now)+1)
+	if ok {
+		t.Error("code older than the last used one was accepted")
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		secret string
+		code   string
+		ok     bool
+	}{
+		{secret, "050 471", true},
+		{secret, "05047", false},
+		{secret, "0504710", false},
+		{secret, "", false},
+		{"not base32!", "050471", false},
+	}
+
+	for _, tt := range tests {
+		_, ok := Validate(tt.secret, tt.code, now, 0)
+		if ok != tt.ok {
+			t.Errorf("%q %q: got %t, want %t", tt.secret, tt.code, ok, tt.ok)
+		}
+	}
+}